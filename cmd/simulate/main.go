@@ -0,0 +1,292 @@
+// Command simulate plays many games between scripted players and reports how
+// often each faction and role wins, to help balance the game settings.
+//
+//	go run ./cmd/simulate -players 7,9 -mafia 2 -games 5000 -format csv
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/silent-vendetta/pkg/game"
+)
+
+// Rate is how often a faction or role won the games it took part in.
+type Rate struct {
+	Name    string  `json:"name"`
+	Played  int     `json:"played"`
+	Wins    int     `json:"wins"`
+	WinRate float64 `json:"winRate"`
+}
+
+// Report holds the statistics for one table size.
+type Report struct {
+	Players int `json:"players"`
+	Games   int `json:"games"`
+	// Unfinished counts games stopped after the round limit.
+	Unfinished int    `json:"unfinished"`
+	Factions   []Rate `json:"factions"`
+	Roles      []Rate `json:"roles"`
+}
+
+type tally map[string]*Rate
+
+func (t tally) add(name string, won bool) {
+	rate, exists := t[name]
+	if !exists {
+		rate = &Rate{Name: name}
+		t[name] = rate
+	}
+	rate.Played++
+	if won {
+		rate.Wins++
+	}
+}
+
+func (t tally) rates() []Rate {
+	rates := make([]Rate, 0, len(t))
+	for _, rate := range t {
+		r := *rate
+		r.WinRate = float64(r.Wins) / float64(r.Played)
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].Name < rates[j].Name
+	})
+	return rates
+}
+
+// stats accumulates the results of the games played at one table size.
+type stats struct {
+	games      int
+	unfinished int
+	factions   tally
+	roles      tally
+	mu         sync.Mutex
+}
+
+func (s *stats) record(snapshot *game.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games++
+	if snapshot.Result == nil {
+		s.unfinished++
+		return
+	}
+
+	// Neutral roles win on their own, so a faction counts as having won when
+	// any of its members did
+	won := make(map[game.Faction]bool)
+	for _, w := range snapshot.Result.Winners {
+		won[w.Faction] = true
+	}
+	present := make(map[game.Faction]bool)
+	for _, p := range snapshot.Players {
+		present[game.FactionOf(p.Role)] = true
+		s.roles.add(p.Role, snapshot.Result.HasWinner(p.ID))
+	}
+	for f := range present {
+		s.factions.add(string(f), won[f])
+	}
+}
+
+// play runs a single game to completion with every player following the
+// strategy. It returns the final snapshot, which has no result if the game
+// was still going after maxRounds.
+func play(settings game.GameSettings, players int, strategy game.BotStrategy, seed int64, maxRounds int) (*game.Snapshot, error) {
+	manager := game.NewGameManager(game.WithSeeder(func() int64 { return seed }))
+	g, err := manager.CreateGame(game.GameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer manager.RemoveGame(g.ID)
+
+	// Size the table before seating anyone, so that more players than the
+	// default maximum fit
+	settings.MinPlayers, settings.MaxPlayers = players, players
+	if err := manager.UpdateSettings(g.ID, settings); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, players)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%02d", i+1)
+		if err := manager.AddPlayer(g.ID, ids[i], ids[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := manager.StartGame(g.ID); err != nil {
+		return nil, err
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	for {
+		snapshot, err := manager.GetGame(g.ID)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.Phase == game.PhaseGameOver || snapshot.Round > maxRounds {
+			return snapshot, nil
+		}
+
+		for _, id := range ids {
+			if err := game.PlayTurn(manager, snapshot, id, strategy, r); err != nil {
+				log.Printf("Game %d: %s could not act: %v", seed, id, err)
+			}
+		}
+		if err := manager.AdvancePhase(g.ID); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func parsePlayers(list string) ([]int, error) {
+	var counts []int
+	for _, field := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 3 {
+			return nil, fmt.Errorf("invalid player count %q", field)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+func writeCSV(w io.Writer, reports []Report) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"players", "kind", "name", "played", "wins", "win_rate"})
+	for _, report := range reports {
+		groups := []struct {
+			kind  string
+			rates []Rate
+		}{
+			{"faction", report.Factions},
+			{"role", report.Roles},
+		}
+		for _, group := range groups {
+			for _, r := range group.rates {
+				out.Write([]string{
+					strconv.Itoa(report.Players),
+					group.kind,
+					r.Name,
+					strconv.Itoa(r.Played),
+					strconv.Itoa(r.Wins),
+					strconv.FormatFloat(r.WinRate, 'f', 4, 64),
+				})
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func main() {
+	settings := game.DefaultSettings()
+	games := flag.Int("games", 1000, "number of games to play per player count")
+	playerList := flag.String("players", "7", "comma-separated player counts to simulate")
+	seed := flag.Int64("seed", 1, "seed of the first game; game i uses seed+i")
+	strategyName := flag.String("strategy", game.BotStrategySuspicion, "strategy every player follows (random or suspicion)")
+	format := flag.String("format", "csv", "output format (csv or json)")
+	maxRounds := flag.Int("max-rounds", 50, "rounds after which a game is abandoned")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of games played in parallel")
+	verbose := flag.Bool("v", false, "log the game engine's output")
+	flag.IntVar(&settings.MafiaCount, "mafia", settings.MafiaCount, "mafia members, including special mafia roles")
+	flag.IntVar(&settings.GodfatherCount, "godfather", settings.GodfatherCount, "godfathers (0 or 1)")
+	flag.IntVar(&settings.RoleblockerCount, "roleblocker", settings.RoleblockerCount, "roleblockers")
+	flag.IntVar(&settings.JesterCount, "jester", settings.JesterCount, "jesters")
+	flag.IntVar(&settings.SerialKillerCount, "serial-killer", settings.SerialKillerCount, "serial killers")
+	flag.IntVar(&settings.VigilanteCount, "vigilante", settings.VigilanteCount, "vigilantes")
+	flag.IntVar(&settings.VigilanteShots, "vigilante-shots", settings.VigilanteShots, "shots each vigilante has")
+	flag.BoolVar(&settings.VigilanteSuicide, "vigilante-suicide", settings.VigilanteSuicide, "vigilantes who kill town die of guilt")
+	flag.IntVar(&settings.BodyguardCount, "bodyguard", settings.BodyguardCount, "bodyguards")
+	flag.IntVar(&settings.MayorCount, "mayor", settings.MayorCount, "mayors")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	counts, err := parsePlayers(*playerList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	strategy, err := game.BotStrategyByName(*strategyName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	reports := make([]Report, 0, len(counts))
+	for _, players := range counts {
+		s := &stats{factions: make(tally), roles: make(tally)}
+
+		seeds := make(chan int64)
+		var wg sync.WaitGroup
+		var failed error
+		var failedOnce sync.Once
+		for w := 0; w < *workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for gameSeed := range seeds {
+					snapshot, err := play(settings, players, strategy, gameSeed, *maxRounds)
+					if err != nil {
+						failedOnce.Do(func() { failed = err })
+						continue
+					}
+					s.record(snapshot)
+				}
+			}()
+		}
+		for i := 0; i < *games; i++ {
+			seeds <- *seed + int64(i)
+		}
+		close(seeds)
+		wg.Wait()
+
+		if failed != nil {
+			fmt.Fprintf(os.Stderr, "%d players: %v\n", players, failed)
+			os.Exit(1)
+		}
+
+		reports = append(reports, Report{
+			Players:    players,
+			Games:      s.games,
+			Unfinished: s.unfinished,
+			Factions:   s.factions.rates(),
+			Roles:      s.roles.rates(),
+		})
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(reports)
+	} else {
+		err = writeCSV(os.Stdout, reports)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
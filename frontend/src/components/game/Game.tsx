@@ -43,7 +43,7 @@ const Game: React.FC = () => {
         type: 'join',
         data: {
           playerName: state?.playerName,
-          isHost: state?.isHost
+          token: state?.token
         }
       }));
     };
@@ -114,20 +114,8 @@ const Game: React.FC = () => {
 
     if (gameState.phase !== 'waiting' && gameState.phase !== 'gameover' && timeRemaining > 0) {
       timer = setInterval(() => {
-        setTimeRemaining(prev => {
-          const newTime = Math.max(0, prev - 1);
-          
-          // If time runs out, automatically advance to next phase
-          if (newTime === 0 && gameState.phase !== 'waiting') {
-            fetch(`http://localhost:3001/api/games/${gameId}/next-phase`, {
-              method: 'POST',
-            }).catch(error => {
-              console.error('Error advancing phase:', error);
-            });
-          }
-          
-          return newTime;
-        });
+        // The server advances the phase itself when the timer runs out
+        setTimeRemaining(prev => Math.max(0, prev - 1));
       }, 1000);
     }
 
@@ -168,6 +156,10 @@ const Game: React.FC = () => {
     
     fetch(`http://localhost:3001/api/games/${gameId}/start`, {
       method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ playerId: state?.playerId, token: state?.token }),
     })
     .then(response => {
       if (!response.ok) {
@@ -254,18 +246,6 @@ const Game: React.FC = () => {
     return '';
   };
 
-  const advancePhase = () => {
-    if (!ws) return;
-    
-    fetch(`http://localhost:3001/api/games/${gameId}/next-phase`, {
-      method: 'POST',
-    })
-    .catch(error => {
-      console.error('Error advancing phase:', error);
-      alert('Failed to advance phase');
-    });
-  };
-
   return (
     <div className="game">
       <div className="game-header">
@@ -321,14 +301,6 @@ const Game: React.FC = () => {
                   <span className="duration">/{getPhaseInfo().duration}s</span>
                 </div>
               </div>
-              {state?.isHost && timeRemaining === 0 && (
-                <button 
-                  className="advance-phase-button"
-                  onClick={advancePhase}
-                >
-                  Next Phase →
-                </button>
-              )}
             </div>
           )}
           {gameState.phase === 'gameover' && (
@@ -53,7 +53,7 @@ const Lobby: React.FC = () => {
       setCreatedGameId(data.gameId);
       setTimeout(() => {
         navigate(`/game/${data.gameId}`, {
-          state: { playerName, isHost: true }
+          state: { playerName, isHost: true, playerId: data.playerId, token: data.token }
         });
       }, 2000); // Give time to see the game ID
     } catch (error) {
@@ -92,7 +92,7 @@ const Lobby: React.FC = () => {
       // Navigate after a short delay
       setTimeout(() => {
         navigate(`/game/${gameId}`, {
-          state: { playerName, isHost: false }
+          state: { playerName, isHost: false, playerId: data.playerId, token: data.token }
         });
       }, 1000);
     } catch (error) {
@@ -24,4 +24,6 @@ export type Phase = 'waiting' | 'night' | 'discuss' | 'vote' | 'gameover';
 export interface LocationState {
   playerName: string;
   isHost: boolean;
+  playerId: string;
+  token: string;
 } 
\ No newline at end of file
@@ -0,0 +1,45 @@
+// Package clock abstracts the passage of time so that everything driven by
+// timers, from phase deadlines to cleanup sweeps, can be driven by hand.
+package clock
+
+import "time"
+
+// Clock tells the time and creates timers.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer delivers the time on its channel once it expires. Timers created
+// by AfterFunc call their function instead and have no channel.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already expired or was stopped.
+	Stop() bool
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,137 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers fire as soon as
+// Advance or Set moves the time past their deadline.
+type Fake struct {
+	now    time.Time
+	timers []*fakeTimer
+	mu     sync.Mutex
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.schedule(d, nil)
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.schedule(d, fn)
+}
+
+// schedule adds a timer that fires d from now, or right away if d is not
+// positive.
+func (f *Fake) schedule(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{
+		clock: f,
+		when:  f.now.Add(d),
+		fn:    fn,
+	}
+	if fn == nil {
+		t.c = make(chan time.Time, 1)
+	}
+	if d <= 0 {
+		t.fire(f.now)
+		return t
+	}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Pending returns the number of timers that have not fired yet, so callers
+// can wait for a component to arm its timer before moving the clock.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.timers)
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock to t and fires every timer that is due, earliest
+// first. The clock never moves backwards.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t.After(f.now) {
+		f.now = t
+	}
+
+	pending := f.timers[:0]
+	var due []*fakeTimer
+	for _, timer := range f.timers {
+		if timer.when.After(f.now) {
+			pending = append(pending, timer)
+		} else {
+			due = append(due, timer)
+		}
+	}
+	f.timers = pending
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].when.Before(due[j].when)
+	})
+	for _, timer := range due {
+		timer.fire(f.now)
+	}
+}
+
+// remove drops a timer that has not fired yet and reports whether it was
+// pending.
+func (f *Fake) remove(t *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, timer := range f.timers {
+		if timer == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *Fake
+	when  time.Time
+	c     chan time.Time
+	fn    func()
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	t.c <- now
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t)
+}
@@ -0,0 +1,311 @@
+// Package config loads the server configuration from a YAML, TOML or JSON
+// file, SV_* environment variables and command-line flags. Each source
+// overrides the one before it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/logging"
+	"github.com/silent-vendetta/pkg/ratelimit"
+	"github.com/silent-vendetta/pkg/server"
+	"github.com/silent-vendetta/pkg/store"
+)
+
+// Storage backends.
+const (
+	// StorageNone keeps games only in memory; they are lost on restart.
+	StorageNone = "none"
+	// StorageFile saves games to Storage.Path on shutdown.
+	StorageFile = "file"
+)
+
+// Config is everything the server command can be configured with.
+type Config struct {
+	Addr string `json:"addr"`
+	TLS  TLS    `json:"tls"`
+	// AllowedOrigins are the origins browsers may connect from. Empty or
+	// "*" allows every origin.
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// MaxGames caps the number of games open at once. Zero means no limit.
+	MaxGames int `json:"maxGames"`
+	// Game holds the settings new lobbies start with.
+	Game     game.GameSettings `json:"game"`
+	Limits   Limits            `json:"limits"`
+	Timeouts Timeouts          `json:"timeouts"`
+	Storage  Storage           `json:"storage"`
+	Log      logging.Config    `json:"log"`
+	Admin    Admin             `json:"admin"`
+}
+
+// TLS points at the certificate and key to serve HTTPS with.
+type TLS struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// Admin configures the admin API.
+type Admin struct {
+	// Token is the bearer token admin requests must carry. The admin API is
+	// disabled without one. It has no flag, so that it does not show up in
+	// the process list.
+	Token string `json:"token"`
+}
+
+// MinAdminTokenLength is the shortest admin token accepted.
+const MinAdminTokenLength = 16
+
+// Limits protect the server from clients that flood it. Rates are written
+// as events/period, such as "5/1m"; "0" turns a rate limit off.
+type Limits struct {
+	// MaxGamesPerIP caps the games one IP address can have open at once.
+	// Zero means no limit.
+	MaxGamesPerIP int `json:"maxGamesPerIP"`
+	// CreateGame and JoinGame limit how often each IP address may create
+	// and join games.
+	CreateGame ratelimit.Rate `json:"createGame"`
+	JoinGame   ratelimit.Rate `json:"joinGame"`
+	// Messages limits how often each websocket connection may send a
+	// message.
+	Messages ratelimit.Rate `json:"messages"`
+	// MaxMessageSize is the largest websocket message accepted, in bytes.
+	// Zero means no limit.
+	MaxMessageSize int `json:"maxMessageSize"`
+}
+
+// Timeouts are the durations the server and its games are paced by.
+type Timeouts struct {
+	Read           Duration `json:"read"`
+	Write          Duration `json:"write"`
+	Idle           Duration `json:"idle"`
+	Shutdown       Duration `json:"shutdown"`
+	SpectatorDelay Duration `json:"spectatorDelay"`
+	BotDelay       Duration `json:"botDelay"`
+	SweepInterval  Duration `json:"sweepInterval"`
+	LobbyTTL       Duration `json:"lobbyTTL"`
+	GameOverGrace  Duration `json:"gameOverGrace"`
+	AbandonedTTL   Duration `json:"abandonedTTL"`
+}
+
+// Storage selects where games are saved between restarts.
+type Storage struct {
+	// Backend is StorageNone or StorageFile. Left empty, games are saved to
+	// Path if it is set.
+	Backend string `json:"backend"`
+	Path    string `json:"path"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "5m".
+type Duration time.Duration
+
+// UnmarshalText parses a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default returns the configuration the server runs with when nothing is
+// set.
+func Default() Config {
+	srv := server.DefaultConfig()
+	janitor := game.DefaultJanitorConfig()
+	return Config{
+		Addr: srv.Addr,
+		Game: game.DefaultSettings(),
+		Limits: Limits{
+			MaxGamesPerIP:  5,
+			CreateGame:     srv.Limits.CreateGame,
+			JoinGame:       srv.Limits.JoinGame,
+			Messages:       srv.Limits.Messages,
+			MaxMessageSize: srv.Limits.MaxMessageSize,
+		},
+		Timeouts: Timeouts{
+			Shutdown:       Duration(srv.ShutdownTimeout),
+			SpectatorDelay: Duration(srv.SpectatorDelay),
+			BotDelay:       Duration(srv.Bots.MaxDelay),
+			SweepInterval:  Duration(janitor.Interval),
+			LobbyTTL:       Duration(janitor.WaitingTTL),
+			GameOverGrace:  Duration(janitor.GameOverGrace),
+			AbandonedTTL:   Duration(janitor.AbandonedTTL),
+		},
+		Log: logging.DefaultConfig(),
+	}
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(field string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Addr == "" {
+		fail("addr", "must be set")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		fail("tls", "certFile and keyFile must be set together")
+	}
+	for _, file := range []struct{ field, path string }{
+		{"tls.certFile", c.TLS.CertFile},
+		{"tls.keyFile", c.TLS.KeyFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			fail(file.field, "%v", err)
+		}
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			fail("allowedOrigins", "%q is not an origin such as https://example.com", origin)
+		}
+	}
+
+	if c.MaxGames < 0 {
+		fail("maxGames", "must not be negative")
+	}
+
+	if err := c.Game.Validate(); err != nil {
+		fail("game", "%v", err)
+	}
+
+	if c.Limits.MaxGamesPerIP < 0 {
+		fail("limits.maxGamesPerIP", "must not be negative")
+	}
+	rates := []struct {
+		field string
+		value ratelimit.Rate
+	}{
+		{"limits.createGame", c.Limits.CreateGame},
+		{"limits.joinGame", c.Limits.JoinGame},
+		{"limits.messages", c.Limits.Messages},
+	}
+	for _, r := range rates {
+		if err := r.value.Validate(); err != nil {
+			fail(r.field, "%v", err)
+		}
+	}
+	if c.Limits.MaxMessageSize < 0 {
+		fail("limits.maxMessageSize", "must not be negative")
+	}
+
+	durations := []struct {
+		field    string
+		value    Duration
+		positive bool
+	}{
+		{"timeouts.read", c.Timeouts.Read, false},
+		{"timeouts.write", c.Timeouts.Write, false},
+		{"timeouts.idle", c.Timeouts.Idle, false},
+		{"timeouts.shutdown", c.Timeouts.Shutdown, true},
+		{"timeouts.spectatorDelay", c.Timeouts.SpectatorDelay, false},
+		{"timeouts.botDelay", c.Timeouts.BotDelay, false},
+		{"timeouts.sweepInterval", c.Timeouts.SweepInterval, true},
+		{"timeouts.lobbyTTL", c.Timeouts.LobbyTTL, true},
+		{"timeouts.gameOverGrace", c.Timeouts.GameOverGrace, true},
+		{"timeouts.abandonedTTL", c.Timeouts.AbandonedTTL, true},
+	}
+	for _, d := range durations {
+		switch {
+		case d.positive && d.value <= 0:
+			fail(d.field, "must be positive")
+		case d.value < 0:
+			fail(d.field, "must not be negative")
+		}
+	}
+
+	switch c.Storage.Backend {
+	case "", StorageNone:
+	case StorageFile:
+		if c.Storage.Path == "" {
+			fail("storage.path", "must be set for the %s backend", StorageFile)
+		}
+	default:
+		fail("storage.backend", "must be %q or %q, not %q", StorageNone, StorageFile, c.Storage.Backend)
+	}
+
+	if c.Admin.Token != "" && len(c.Admin.Token) < MinAdminTokenLength {
+		fail("admin.token", "must be at least %d characters", MinAdminTokenLength)
+	}
+
+	if err := c.Log.Validate(); err != nil {
+		fail("log", "%v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Server returns the settings for the HTTP server.
+func (c Config) Server() server.Config {
+	config := server.DefaultConfig()
+	config.Addr = c.Addr
+	config.TLSCertFile = c.TLS.CertFile
+	config.TLSKeyFile = c.TLS.KeyFile
+	config.AllowedOrigins = c.AllowedOrigins
+	config.ReadTimeout = time.Duration(c.Timeouts.Read)
+	config.WriteTimeout = time.Duration(c.Timeouts.Write)
+	config.IdleTimeout = time.Duration(c.Timeouts.Idle)
+	config.ShutdownTimeout = time.Duration(c.Timeouts.Shutdown)
+	config.SpectatorDelay = time.Duration(c.Timeouts.SpectatorDelay)
+	config.Bots.MaxDelay = time.Duration(c.Timeouts.BotDelay)
+	config.Limits = server.Limits{
+		CreateGame:     c.Limits.CreateGame,
+		JoinGame:       c.Limits.JoinGame,
+		Messages:       c.Limits.Messages,
+		MaxMessageSize: c.Limits.MaxMessageSize,
+	}
+	config.AdminToken = c.Admin.Token
+	// The server logs its address itself, in the configured log format
+	config.DisableStartupMessage = true
+	return config
+}
+
+// Janitor returns the settings for sweeping stale games.
+func (c Config) Janitor() game.JanitorConfig {
+	return game.JanitorConfig{
+		Interval:      time.Duration(c.Timeouts.SweepInterval),
+		WaitingTTL:    time.Duration(c.Timeouts.LobbyTTL),
+		GameOverGrace: time.Duration(c.Timeouts.GameOverGrace),
+		AbandonedTTL:  time.Duration(c.Timeouts.AbandonedTTL),
+	}
+}
+
+// GameOptions returns the options for the game manager.
+func (c Config) GameOptions() []game.Option {
+	return []game.Option{
+		game.WithDefaultSettings(c.Game),
+		game.WithMaxGames(c.MaxGames),
+		game.WithMaxGamesPerOwner(c.Limits.MaxGamesPerIP),
+	}
+}
+
+// Store returns the configured storage backend, or nil if games are not
+// saved.
+func (c Config) Store() store.Store {
+	if c.Storage.Backend == StorageFile || (c.Storage.Backend == "" && c.Storage.Path != "") {
+		return store.NewFile(c.Storage.Path)
+	}
+	return nil
+}
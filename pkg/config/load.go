@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of every environment variable the configuration
+// is read from. Variables are named after the fields of the configuration
+// file, so timeouts.lobbyTTL is set by SV_TIMEOUTS_LOBBY_TTL.
+const EnvPrefix = "SV"
+
+// Load builds the configuration from the defaults, the file named by the
+// -config flag or SV_CONFIG, the SV_* variables found by lookupEnv and the
+// flags in args, in that order, then validates it.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", "", "YAML, TOML or JSON file to read the configuration from")
+	bindFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// Flags win over the file and the environment, so keep the ones that
+	// were given and set them again once those are loaded.
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	cfg = Default()
+
+	if *path == "" {
+		*path, _ = lookupEnv(EnvPrefix + "_CONFIG")
+	}
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := errors.Join(applyEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix, lookupEnv)...); err != nil {
+		return Config{}, err
+	}
+	for name, value := range given {
+		if err := fs.Set(name, value); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// bindFlags registers the command-line flags, which cover everything but
+// the game settings and the admin token.
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "address to listen on")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "certificate file to serve HTTPS with")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "key file to serve HTTPS with")
+	fs.Var((*listValue)(&cfg.AllowedOrigins), "allowed-origins", "comma-separated origins browsers may connect from")
+	fs.IntVar(&cfg.MaxGames, "max-games", cfg.MaxGames, "most games open at once, 0 for no limit")
+	fs.IntVar(&cfg.Limits.MaxGamesPerIP, "max-games-per-ip", cfg.Limits.MaxGamesPerIP, "most games one IP address can have open at once, 0 for no limit")
+	fs.TextVar(&cfg.Limits.CreateGame, "create-rate", cfg.Limits.CreateGame, "how often each IP address may create a game, such as 5/1m, 0 for no limit")
+	fs.TextVar(&cfg.Limits.JoinGame, "join-rate", cfg.Limits.JoinGame, "how often each IP address may join a game, 0 for no limit")
+	fs.TextVar(&cfg.Limits.Messages, "message-rate", cfg.Limits.Messages, "how often each websocket connection may send a message, 0 for no limit")
+	fs.IntVar(&cfg.Limits.MaxMessageSize, "max-message-size", cfg.Limits.MaxMessageSize, "largest websocket message accepted in bytes, 0 for no limit")
+
+	durations := []struct {
+		name  string
+		value *Duration
+		usage string
+	}{
+		{"read-timeout", &cfg.Timeouts.Read, "longest time to read a request"},
+		{"write-timeout", &cfg.Timeouts.Write, "longest time to write a response"},
+		{"idle-timeout", &cfg.Timeouts.Idle, "how long idle keep-alive connections are kept open"},
+		{"shutdown-timeout", &cfg.Timeouts.Shutdown, "how long a graceful shutdown may take"},
+		{"spectator-delay", &cfg.Timeouts.SpectatorDelay, "how long omniscient spectators lag behind the game"},
+		{"bot-delay", &cfg.Timeouts.BotDelay, "longest a bot waits before acting in a phase"},
+		{"sweep-interval", &cfg.Timeouts.SweepInterval, "how often stale games are swept"},
+		{"lobby-ttl", &cfg.Timeouts.LobbyTTL, "how long an idle lobby is kept before it expires"},
+		{"gameover-grace", &cfg.Timeouts.GameOverGrace, "how long a finished game is kept before it is archived"},
+		{"abandoned-ttl", &cfg.Timeouts.AbandonedTTL, "how long a game in progress is kept without connected players"},
+	}
+	for _, d := range durations {
+		fs.DurationVar((*time.Duration)(d.value), d.name, time.Duration(*d.value), d.usage)
+	}
+
+	fs.StringVar(&cfg.Storage.Backend, "storage", cfg.Storage.Backend, "where games are saved between restarts: none or file")
+	fs.StringVar(&cfg.Storage.Path, "state-file", cfg.Storage.Path, "file where running games are saved on shutdown and restored on start")
+
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "lowest level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log format: text or json")
+	fs.BoolVar(&cfg.Log.Debug, "log-debug", cfg.Log.Debug, "log roles and night actions in the clear; never use where players can read the logs")
+}
+
+// listValue is a flag holding a comma-separated list.
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+// loadFile reads the configuration file over cfg. The format is chosen by
+// the file extension.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("%s: unknown format %q, use .yaml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Every format goes through JSON so they share the field names and
+	// duration parsing, and misspelt fields are caught.
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv sets the fields of the struct v from the environment variables
+// named after them under prefix.
+func applyEnv(v reflect.Value, prefix string, lookupEnv func(string) (string, bool)) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + envName(tag)
+		field := v.Field(i)
+
+		if _, isText := field.Addr().Interface().(encoding.TextUnmarshaler); field.Kind() == reflect.Struct && !isText {
+			errs = append(errs, applyEnv(field, name, lookupEnv)...)
+			continue
+		}
+
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// setField parses value into a configuration field.
+func setField(field reflect.Value, value string) error {
+	if text, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return text.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(value)))
+	default:
+		return fmt.Errorf("cannot set %s fields", field.Kind())
+	}
+	return nil
+}
+
+// envName turns a camelCase field name into SCREAMING_SNAKE_CASE.
+func envName(field string) string {
+	var b strings.Builder
+	runes := []rune(field)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// splitList splits a comma-separated list, dropping blank entries.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/silent-vendetta/pkg/ratelimit"
+)
+
+// TestLoad checks that the defaults, the file, the environment and the
+// flags are layered in that order.
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to config.yaml and passed with -config, or with
+		// SV_CONFIG if configEnv is set.
+		file      string
+		configEnv bool
+		env       map[string]string
+		args      []string
+		// want changes the defaults into the expected configuration.
+		want    func(*Config)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: func(*Config) {},
+		},
+		{
+			name: "file",
+			file: "addr: \":4000\"\ntimeouts:\n  lobbyTTL: 1h\n",
+			want: func(c *Config) {
+				c.Addr = ":4000"
+				c.Timeouts.LobbyTTL = Duration(time.Hour)
+			},
+		},
+		{
+			name:      "file named by the environment",
+			file:      "addr: \":4000\"\n",
+			configEnv: true,
+			want:      func(c *Config) { c.Addr = ":4000" },
+		},
+		{
+			name: "environment over file",
+			file: "addr: \":4000\"\nmaxGames: 7\n",
+			env:  map[string]string{"SV_ADDR": ":5000"},
+			want: func(c *Config) {
+				c.Addr = ":5000"
+				c.MaxGames = 7
+			},
+		},
+		{
+			name: "flags over environment",
+			file: "addr: \":4000\"\n",
+			env:  map[string]string{"SV_ADDR": ":5000", "SV_MAX_GAMES": "9"},
+			args: []string{"-addr", ":6000"},
+			want: func(c *Config) {
+				c.Addr = ":6000"
+				c.MaxGames = 9
+			},
+		},
+		{
+			name: "nested fields",
+			env: map[string]string{
+				"SV_TIMEOUTS_LOBBY_TTL":      "1h",
+				"SV_LIMITS_CREATE_GAME":      "0",
+				"SV_LIMITS_MAX_GAMES_PER_IP": "2",
+				"SV_GAME_MAFIA_COUNT":        "1",
+				"SV_ALLOWED_ORIGINS":         "https://a.example, https://b.example",
+				"SV_LOG_DEBUG":               "true",
+			},
+			args: []string{"-lobby-ttl", "2h"},
+			want: func(c *Config) {
+				c.Timeouts.LobbyTTL = Duration(2 * time.Hour)
+				c.Limits.CreateGame = ratelimit.Rate{}
+				c.Limits.MaxGamesPerIP = 2
+				c.Game.MafiaCount = 1
+				c.AllowedOrigins = []string{"https://a.example", "https://b.example"}
+				c.Log.Debug = true
+			},
+		},
+		{
+			name: "flag set to the default still wins",
+			env:  map[string]string{"SV_ADDR": ":5000"},
+			args: []string{"-addr", Default().Addr},
+			want: func(*Config) {},
+		},
+		{
+			name:    "unknown file field",
+			file:    "adress: \":4000\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad environment value",
+			env:     map[string]string{"SV_MAX_GAMES": "lots"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-no-such-flag"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid result",
+			args:    []string{"-max-games", "-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := make(map[string]string)
+			for name, value := range tt.env {
+				env[name] = value
+			}
+			var args []string
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if tt.configEnv {
+					env[EnvPrefix+"_CONFIG"] = path
+				} else {
+					args = append(args, "-config", path)
+				}
+			}
+			args = append(args, tt.args...)
+			lookupEnv := func(name string) (string, bool) {
+				value, ok := env[name]
+				return value, ok
+			}
+
+			got, err := Load(args, lookupEnv)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Load(%q) succeeded", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load(%q): %v", args, err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load(%q) =\n%+v\nwant\n%+v", args, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package game
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// BotStrategy decides what a bot does. It only ever sees the bot's own view
+// of the game, so it cannot cheat.
+type BotStrategy interface {
+	// NightTarget returns the target of the bot's night action or mafia
+	// vote, or "" to do nothing tonight.
+	NightTarget(view *Snapshot, self *Player, r *rand.Rand) string
+	// DayVote returns who the bot votes to eliminate, or "" to abstain.
+	DayVote(view *Snapshot, self *Player, r *rand.Rand) string
+}
+
+// BotConfig controls how bots pace their actions.
+type BotConfig struct {
+	// MaxDelay is the longest a bot waits after a phase starts before it
+	// acts, so that bots do not all move at the same instant.
+	MaxDelay time.Duration
+}
+
+// DefaultBotConfig returns the bot settings used by the server.
+func DefaultBotConfig() BotConfig {
+	return BotConfig{
+		MaxDelay: 5 * time.Second,
+	}
+}
+
+// Bots seats bot players in lobbies and plays their turns. Bots act through
+// the same GameManager commands as human players.
+type Bots struct {
+	manager *GameManager
+	config  BotConfig
+	// strategies maps a game ID to the strategy of each of its bots.
+	strategies map[string]map[string]BotStrategy
+	rng        *rand.Rand
+	mu         sync.Mutex
+}
+
+func NewBots(manager *GameManager, config BotConfig) *Bots {
+	b := &Bots{
+		manager:    manager,
+		config:     config,
+		strategies: make(map[string]map[string]BotStrategy),
+		rng:        rand.New(manager.newSource(manager.seeder())),
+	}
+	manager.Subscribe(b.handle)
+	return b
+}
+
+// Add seats a new bot in a lobby on behalf of the host and returns its
+// player ID.
+func (b *Bots) Add(gameID string, hostID string, strategy BotStrategy) (string, error) {
+	botID := "bot-" + uuid.New().String()
+
+	// Register the strategy first so the bot cannot miss the first night
+	b.mu.Lock()
+	if b.strategies[gameID] == nil {
+		b.strategies[gameID] = make(map[string]BotStrategy)
+	}
+	b.strategies[gameID][botID] = strategy
+	b.mu.Unlock()
+
+	if err := b.manager.AddBot(gameID, hostID, botID); err != nil {
+		b.mu.Lock()
+		delete(b.strategies[gameID], botID)
+		b.mu.Unlock()
+		return "", err
+	}
+
+	return botID, nil
+}
+
+// handle reacts to game events. It runs on the game's goroutine, so bots
+// act from timers of their own.
+func (b *Bots) handle(event Event) {
+	switch event.Type {
+	case EventGameRemoved:
+		b.mu.Lock()
+		delete(b.strategies, event.GameID)
+		b.mu.Unlock()
+
+	case EventPhaseChanged:
+		if event.Snapshot.Phase != PhaseNight && event.Snapshot.Phase != PhaseVote {
+			return
+		}
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for botID, strategy := range b.strategies[event.GameID] {
+			var delay time.Duration
+			if b.config.MaxDelay > 0 {
+				delay = time.Duration(b.rng.Int63n(int64(b.config.MaxDelay)))
+			}
+			r := rand.New(b.manager.newSource(b.rng.Int63()))
+			snapshot, botID, strategy := event.Snapshot, botID, strategy
+			b.manager.clock.AfterFunc(delay, func() {
+				b.act(snapshot, botID, strategy, r)
+			})
+		}
+	}
+}
+
+// act plays a bot's turn for the phase of the given snapshot, unless the
+// game has moved on by the time the bot is ready.
+func (b *Bots) act(phase *Snapshot, botID string, strategy BotStrategy, r *rand.Rand) {
+	snapshot, err := b.manager.GetGame(phase.ID)
+	if err != nil || snapshot.Phase != phase.Phase || snapshot.Round != phase.Round {
+		return
+	}
+
+	if err := PlayTurn(b.manager, snapshot, botID, strategy, r); err != nil {
+		b.manager.logger.Warn("bot could not act", logging.KeyGameID, phase.ID, logging.KeyPlayerID, botID, "error", err)
+	}
+}
+
+// PlayTurn asks strategy what the player does in the phase of snapshot and
+// submits it through the same commands a human would use. Dead players and
+// players with nothing to do in the phase are skipped.
+func PlayTurn(manager *GameManager, snapshot *Snapshot, playerID string, strategy BotStrategy, r *rand.Rand) error {
+	player, exists := snapshot.Players[playerID]
+	if !exists || !player.IsAlive {
+		return nil
+	}
+
+	view := snapshot.PlayerView(playerID)
+	self := view.Players[playerID]
+
+	switch snapshot.Phase {
+	case PhaseNight:
+		if !player.IsMafia() && roles[player.Role].Night == NightActionNone {
+			return nil
+		}
+		target := strategy.NightTarget(view, self, r)
+		if target == "" {
+			return nil
+		}
+		if !player.IsMafia() {
+			return manager.HandleNightAction(snapshot.ID, playerID, target)
+		}
+		if err := manager.HandleMafiaAction(snapshot.ID, playerID, target); err != nil {
+			return err
+		}
+		// A roleblocker blocks someone other than the player the team is
+		// killing, since blocking the victim achieves nothing
+		if roles[player.Role].Night != NightActionNone {
+			if block := blockTarget(view, self, target, r); block != "" {
+				return manager.HandleNightAction(snapshot.ID, playerID, block)
+			}
+		}
+
+	case PhaseVote:
+		if target := strategy.DayVote(view, self, r); target != "" {
+			return manager.HandleVote(snapshot.ID, playerID, target)
+		}
+	}
+
+	return nil
+}
+
+// blockTarget picks who a mafia bot uses its own night action on: a living
+// player outside the mafia whom no mafia member is voting to kill, kill
+// included. Revealed mayors have no night action worth blocking, so the
+// block goes to someone who may be the detective or the medic.
+func blockTarget(view *Snapshot, self *Player, kill string, r *rand.Rand) string {
+	targeted := map[string]bool{kill: true}
+	for _, p := range view.Players {
+		if p.IsMafia() && p.IsAlive && p.VotedFor != "" {
+			targeted[p.VotedFor] = true
+		}
+	}
+
+	var options []*Player
+	for _, p := range candidates(view, self) {
+		if !targeted[p.ID] && !p.Revealed {
+			options = append(options, p)
+		}
+	}
+	return pickRandom(options, r)
+}
@@ -0,0 +1,67 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRoleblockerBotSparesKillTarget checks that a roleblocker bot never
+// blocks the player the mafia is killing, or a fellow mafia member.
+func TestRoleblockerBotSparesKillTarget(t *testing.T) {
+	settings := DefaultSettings()
+	settings.RoleblockerCount = 1
+	m := newTestManager(WithDefaultSettings(settings), WithSeeder(func() int64 { return 1 }))
+	created, err := m.CreateGame(GameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ann", "ben", "cal", "dee", "eve", "fay", "gus"} {
+		if err := m.AddPlayer(created.ID, name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.StartGame(created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := m.GetGame(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocker string
+	for id, p := range snapshot.Players {
+		if p.Role == RoleRoleblocker {
+			blocker = id
+		}
+	}
+	if blocker == "" {
+		t.Fatal("no roleblocker was dealt")
+	}
+
+	for _, strategy := range []BotStrategy{RandomStrategy{}, SuspicionStrategy{}} {
+		for seed := int64(0); seed < 50; seed++ {
+			snapshot, err := m.GetGame(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := PlayTurn(m, snapshot, blocker, strategy, rand.New(rand.NewSource(seed))); err != nil {
+				t.Fatal(err)
+			}
+
+			after, err := m.GetGame(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			self := after.Players[blocker]
+			block := after.Players[self.NightTarget]
+			switch {
+			case block == nil:
+				t.Fatalf("%T seed %d: roleblocker blocked nobody", strategy, seed)
+			case block.ID == self.VotedFor:
+				t.Fatalf("%T seed %d: roleblocker blocked its own kill target %s", strategy, seed, block.ID)
+			case block.IsMafia():
+				t.Fatalf("%T seed %d: roleblocker blocked fellow mafia %s", strategy, seed, block.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// codeAlphabet leaves out characters that are easily confused when read
+// aloud or handwritten: 0/O, 1/I/L.
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// Join codes are CodeLength characters long, growing to MaxCodeLength if the
+// shorter codes keep colliding.
+const (
+	CodeLength    = 5
+	MaxCodeLength = 6
+	codeAttempts  = 10
+)
+
+// NormalizeCode converts user input into the canonical form of a join code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func randomCode(length int) string {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic("game: unable to generate join code: " + err.Error())
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return string(b)
+}
+
+// allocateCode reserves an unused join code for a game. Callers must hold m.mu.
+func (m *GameManager) allocateCode(gameID string) (string, error) {
+	for length := CodeLength; length <= MaxCodeLength; length++ {
+		for attempt := 0; attempt < codeAttempts; attempt++ {
+			code := randomCode(length)
+			if _, taken := m.codes[code]; !taken {
+				m.codes[code] = gameID
+				return code, nil
+			}
+		}
+	}
+	return "", ErrNoCodeAvailable
+}
+
+// GetGameByCode returns the latest snapshot of the game with the join code.
+func (m *GameManager) GetGameByCode(code string) (*Snapshot, error) {
+	m.mu.RLock()
+	gameID, exists := m.codes[NormalizeCode(code)]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, ErrGameNotFound
+	}
+	return m.GetGame(gameID)
+}
+
+// ResolveGameID accepts either a game ID or a join code and returns the ID of
+// the game it refers to.
+func (m *GameManager) ResolveGameID(idOrCode string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.games[idOrCode]; exists {
+		return idOrCode, nil
+	}
+	if gameID, exists := m.codes[NormalizeCode(idOrCode)]; exists {
+		return gameID, nil
+	}
+	return "", ErrGameNotFound
+}
@@ -1,17 +1,145 @@
 package game
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies an error to clients, which can act on it without parsing
+// the message.
+type Code string
+
+const (
+	CodeGameFull           Code = "game_full"
+	CodeGameNotFound       Code = "game_not_found"
+	CodePlayerNotFound     Code = "player_not_found"
+	CodeInvalidPhase       Code = "invalid_phase"
+	CodeNotEnoughPlayers   Code = "not_enough_players"
+	CodeGameAlreadyStarted Code = "game_already_started"
+	CodePlayerNotAlive     Code = "player_not_alive"
+	CodeInvalidVote        Code = "invalid_vote"
+	CodePlayerNameTaken    Code = "player_name_taken"
+	CodePlayerExists       Code = "player_already_exists"
+	CodeNotMafia           Code = "not_mafia"
+	CodeNoNightAction      Code = "no_night_action"
+	CodeInvalidTarget      Code = "invalid_target"
+	CodeInvalidSettings    Code = "invalid_settings"
+	CodeNoShotsLeft        Code = "no_shots_left"
+	CodeCannotReveal       Code = "cannot_reveal"
+	CodeInvalidGameName    Code = "invalid_game_name"
+	CodeInvalidVisibility  Code = "invalid_visibility"
+	CodeNoCodeAvailable    Code = "no_code_available"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodePasswordTooLong    Code = "password_too_long"
+	CodeTooManyAttempts    Code = "too_many_attempts"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeNotHost            Code = "not_host"
+	CodeUnknownBotStrategy Code = "unknown_bot_strategy"
+	CodeTooManyGames       Code = "too_many_games"
+	CodeTooManyOwnGames    Code = "too_many_own_games"
+	CodeInvalidPlayerName  Code = "invalid_player_name"
+	CodeReservedPlayerName Code = "reserved_player_name"
+	// CodeInternal is reported for errors that are not GameErrors.
+	CodeInternal Code = "internal"
+)
+
+// GameError is an error with a code clients can act on. The Err* sentinels
+// are all GameErrors, so errors.Is and == keep matching them.
+type GameError struct {
+	Code Code
+	Err  error
+}
+
+func newError(code Code, message string) *GameError {
+	return &GameError{Code: code, Err: errors.New(message)}
+}
+
+func (e *GameError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GameError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the code of the first GameError in err, or CodeInternal
+// if it has none.
+func ErrorCode(err error) Code {
+	var gameErr *GameError
+	if errors.As(err, &gameErr) {
+		return gameErr.Code
+	}
+	return CodeInternal
+}
 
 var (
-	ErrGameFull            = errors.New("game is full")
-	ErrGameNotFound        = errors.New("game not found")
-	ErrPlayerNotFound      = errors.New("player not found")
-	ErrInvalidPhase        = errors.New("invalid game phase")
-	ErrNotEnoughPlayers    = errors.New("not enough players to start game")
-	ErrGameAlreadyStarted  = errors.New("game has already started")
-	ErrPlayerNotAlive      = errors.New("player is not alive")
-	ErrInvalidVote         = errors.New("invalid vote")
-	ErrPlayerNameTaken     = errors.New("player name already taken")
-	ErrPlayerAlreadyExists = errors.New("player already exists")
-	ErrNotMafia            = errors.New("player is not mafia")
+	ErrGameFull            = newError(CodeGameFull, "game is full")
+	ErrGameNotFound        = newError(CodeGameNotFound, "game not found")
+	ErrPlayerNotFound      = newError(CodePlayerNotFound, "player not found")
+	ErrInvalidPhase        = newError(CodeInvalidPhase, "invalid game phase")
+	ErrNotEnoughPlayers    = newError(CodeNotEnoughPlayers, "not enough players to start game")
+	ErrGameAlreadyStarted  = newError(CodeGameAlreadyStarted, "game has already started")
+	ErrPlayerNotAlive      = newError(CodePlayerNotAlive, "player is not alive")
+	ErrInvalidVote         = newError(CodeInvalidVote, "invalid vote")
+	ErrPlayerNameTaken     = newError(CodePlayerNameTaken, "player name already taken")
+	ErrPlayerAlreadyExists = newError(CodePlayerExists, "player already exists")
+	ErrNotMafia            = newError(CodeNotMafia, "player is not mafia")
+	ErrNoNightAction       = newError(CodeNoNightAction, "player has no night action")
+	ErrInvalidTarget       = newError(CodeInvalidTarget, "invalid target")
+	ErrInvalidSettings     = newError(CodeInvalidSettings, "invalid game settings")
+	ErrNoShotsLeft         = newError(CodeNoShotsLeft, "no shots left")
+	ErrCannotReveal        = newError(CodeCannotReveal, "player cannot reveal")
+	ErrInvalidGameName     = newError(CodeInvalidGameName, "invalid game name")
+	ErrInvalidVisibility   = newError(CodeInvalidVisibility, "invalid game visibility")
+	ErrNoCodeAvailable     = newError(CodeNoCodeAvailable, "no join code available")
+	ErrInvalidPassword     = newError(CodeInvalidPassword, "invalid lobby password")
+	ErrPasswordTooLong     = newError(CodePasswordTooLong, "lobby password is too long")
+	ErrTooManyAttempts     = newError(CodeTooManyAttempts, "too many failed password attempts, try again later")
+	ErrInvalidToken        = newError(CodeInvalidToken, "invalid player token")
+	ErrNotHost             = newError(CodeNotHost, "player is not the host")
+	ErrUnknownBotStrategy  = newError(CodeUnknownBotStrategy, "unknown bot strategy")
+	ErrTooManyGames        = newError(CodeTooManyGames, "too many games, try again later")
+	ErrTooManyOwnGames     = newError(CodeTooManyOwnGames, "you have too many open games")
+	ErrInvalidPlayerName   = newError(CodeInvalidPlayerName, "invalid player name")
+	ErrReservedPlayerName  = newError(CodeReservedPlayerName, "player name is reserved")
 )
+
+// FieldError reports why one field of a request is invalid. It wraps the
+// sentinel error for the failure, so errors.Is still matches it.
+type FieldError struct {
+	// Field is the name of the field as clients send it, such as
+	// "playerName".
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors returns every *FieldError in err, including those joined with
+// errors.Join.
+func FieldErrors(err error) []*FieldError {
+	var fields []*FieldError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *FieldError:
+			fields = append(fields, e)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return fields
+}
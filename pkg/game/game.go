@@ -1,10 +1,14 @@
 package game
 
 import (
-	"sync"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/silent-vendetta/pkg/clock"
+	"github.com/silent-vendetta/pkg/logging"
 )
 
 type Role = string
@@ -15,6 +19,16 @@ const (
 	RoleVillager  Role = "villager"
 	RoleDetective Role = "detective"
 	RoleMedic     Role = "medic"
+
+	RoleVigilante Role = "vigilante"
+	RoleBodyguard Role = "bodyguard"
+	RoleMayor     Role = "mayor"
+
+	RoleGodfather   Role = "godfather"
+	RoleRoleblocker Role = "roleblocker"
+
+	RoleJester       Role = "jester"
+	RoleSerialKiller Role = "serialKiller"
 )
 
 type Phase string
@@ -27,45 +41,202 @@ const (
 	PhaseGameOver Phase = "gameover"
 )
 
+// Phases lists every phase in the order a game goes through them.
+var Phases = []Phase{PhaseWaiting, PhaseNight, PhaseDiscuss, PhaseVote, PhaseGameOver}
+
 type Player struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Role     Role   `json:"role"`
-	IsAlive  bool   `json:"isAlive"`
-	IsHost   bool   `json:"isHost"`
-	VotedFor string `json:"votedFor,omitempty"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Role        Role   `json:"role"`
+	IsAlive     bool   `json:"isAlive"`
+	IsHost      bool   `json:"isHost"`
+	VotedFor    string `json:"votedFor,omitempty"`
+	NightTarget string `json:"-"`
+	Revealed    bool   `json:"revealed,omitempty"`
+	IsBot       bool   `json:"isBot,omitempty"`
+	// Token proves a connection speaks for the player. Player IDs are
+	// public, so the token is only ever given to the player when seated.
+	Token string `json:"-"`
+
+	Investigations []Investigation `json:"-"`
+	// Shots is the number of night kills a vigilante has left.
+	Shots int `json:"-"`
 }
 
-type Game struct {
+// voteWeight is the number of votes the player casts during the day.
+func (p *Player) voteWeight() int {
+	if p.Role == RoleMayor && p.Revealed {
+		return 3
+	}
+	return 1
+}
+
+// GameSettings holds the lobby configuration chosen by the host.
+type GameSettings struct {
+	MinPlayers        int `json:"minPlayers"`
+	MaxPlayers        int `json:"maxPlayers"`
+	MafiaCount        int `json:"mafiaCount"`
+	GodfatherCount    int `json:"godfatherCount"`
+	RoleblockerCount  int `json:"roleblockerCount"`
+	JesterCount       int `json:"jesterCount"`
+	SerialKillerCount int `json:"serialKillerCount"`
+	VigilanteCount    int `json:"vigilanteCount"`
+	VigilanteShots    int `json:"vigilanteShots"`
+	// VigilanteSuicide makes a vigilante who kills a town member die of guilt.
+	VigilanteSuicide bool `json:"vigilanteSuicide"`
+	BodyguardCount   int  `json:"bodyguardCount"`
+	MayorCount       int  `json:"mayorCount"`
+}
+
+// DefaultSettings returns the settings new games start with.
+func DefaultSettings() GameSettings {
+	return GameSettings{
+		MinPlayers:     4,
+		MaxPlayers:     10,
+		MafiaCount:     2,
+		VigilanteShots: 2,
+	}
+}
+
+// Validate checks that the settings describe a playable game.
+func (s GameSettings) Validate() error {
+	if s.MinPlayers < 3 || s.MaxPlayers < s.MinPlayers {
+		return ErrInvalidSettings
+	}
+	if s.MafiaCount < 1 || s.JesterCount < 0 || s.SerialKillerCount < 0 {
+		return ErrInvalidSettings
+	}
+	if s.GodfatherCount < 0 || s.GodfatherCount > 1 || s.RoleblockerCount < 0 {
+		return ErrInvalidSettings
+	}
+	if s.GodfatherCount+s.RoleblockerCount > s.MafiaCount {
+		return ErrInvalidSettings
+	}
+	if s.VigilanteCount < 0 || s.BodyguardCount < 0 || s.MayorCount < 0 {
+		return ErrInvalidSettings
+	}
+	if s.VigilanteCount > 0 && s.VigilanteShots < 1 {
+		return ErrInvalidSettings
+	}
+	return nil
+}
+
+// roleDeck returns the roles to deal for the given number of players. Mafia
+// are capped at a third of the table and neutral roles only take seats while
+// at least one town member remains.
+func (s GameSettings) roleDeck(players int) []Role {
+	deck := make([]Role, 0, players)
+	add := func(role Role, count int) {
+		for i := 0; i < count && len(deck) < players-1; i++ {
+			deck = append(deck, role)
+		}
+	}
+
+	mafiaCount := s.MafiaCount
+	if mafiaCount > players/3 {
+		mafiaCount = players / 3
+	}
+	// Special mafia roles take seats from the mafia count
+	godfathers := min(s.GodfatherCount, mafiaCount)
+	roleblockers := min(s.RoleblockerCount, mafiaCount-godfathers)
+	add(RoleGodfather, godfathers)
+	add(RoleRoleblocker, roleblockers)
+	add(RoleMafia, mafiaCount-godfathers-roleblockers)
+	add(RoleSerialKiller, s.SerialKillerCount)
+	add(RoleJester, s.JesterCount)
+
+	// Special town roles (detective and medic)
+	if players >= 5 {
+		add(RoleDetective, 1)
+	}
+	if players >= 7 {
+		add(RoleMedic, 1)
+	}
+	add(RoleVigilante, s.VigilanteCount)
+	add(RoleBodyguard, s.BodyguardCount)
+	add(RoleMayor, s.MayorCount)
+
+	for len(deck) < players {
+		deck = append(deck, RoleVillager)
+	}
+	return deck
+}
+
+// State is the serializable state of a game.
+type State struct {
 	ID           string             `json:"id"`
+	Code         string             `json:"code"`
+	Name         string             `json:"name,omitempty"`
+	Visibility   Visibility         `json:"visibility"`
+	HasPassword  bool               `json:"hasPassword"`
 	Players      map[string]*Player `json:"players"`
 	Phase        Phase              `json:"phase"`
 	Round        int                `json:"round"`
 	PhaseEndTime time.Time          `json:"phaseEndTime"`
-	MinPlayers   int                `json:"minPlayers"`
-	MaxPlayers   int                `json:"maxPlayers"`
-	MafiaCount   int                `json:"mafiaCount"`
-	mu           sync.RWMutex       `json:"-"`
+	GameSettings
+	Deaths    []Death     `json:"deaths"`
+	Result    *GameResult `json:"result,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
 }
 
-func NewGame(id string) *Game {
-	return &Game{
-		ID:         id,
-		Players:    make(map[string]*Player),
-		Phase:      PhaseWaiting,
-		Round:      0,
-		MinPlayers: 4,
-		MaxPlayers: 10,
-		MafiaCount: 2,
-	}
+// Game is a single match. Its state is owned by a dedicated goroutine that
+// applies commands one at a time; everyone else reads published Snapshots.
+type Game struct {
+	State
+	Seed         int64
+	soloWinners  []string
+	passwordHash []byte
+	// owner is who opened the lobby, as given in GameOptions.Owner.
+	owner string
+
+	cmds     chan command
+	done     chan struct{}
+	clock    clock.Clock
+	logger   *slog.Logger
+	notify   func(Event)
+	snapshot atomic.Pointer[Snapshot]
 }
 
-func (g *Game) AddPlayer(name, playerID string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+// log returns the game's logger annotated with the current phase and round.
+func (g *Game) log() *slog.Logger {
+	return g.logger.With(logging.KeyPhase, g.Phase, logging.KeyRound, g.Round)
+}
 
+// newGame creates a game in the lobby phase from the identifying fields and
+// settings of info and starts its goroutine. A non-empty passwordHash
+// protects the lobby.
+func newGame(info State, passwordHash []byte, clock clock.Clock, logger *slog.Logger, notify func(Event)) *Game {
+	now := clock.Now()
+	g := &Game{
+		State: State{
+			ID:           info.ID,
+			Code:         info.Code,
+			Name:         info.Name,
+			Visibility:   info.Visibility,
+			HasPassword:  len(passwordHash) > 0,
+			Players:      make(map[string]*Player),
+			Phase:        PhaseWaiting,
+			Round:        0,
+			GameSettings: info.GameSettings,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		passwordHash: passwordHash,
+		cmds:         make(chan command),
+		done:         make(chan struct{}),
+		clock:        clock,
+		logger:       logger.With(logging.KeyGameID, info.ID),
+		notify:       notify,
+	}
+	g.publish()
+	go g.run()
+	return g
+}
+
+func (g *Game) addPlayer(name, playerID string) (*Player, error) {
 	if len(g.Players) >= g.MaxPlayers {
-		return ErrGameFull
+		return nil, ErrGameFull
 	}
 
 	// Generate a unique ID for the player if not provided
@@ -73,69 +244,65 @@ func (g *Game) AddPlayer(name, playerID string) error {
 		playerID = uuid.New().String()
 	}
 
-	// Check if player with same name already exists
-	for _, p := range g.Players {
-		if p.Name == name {
-			return ErrPlayerNameTaken
-		}
+	// Names that differ only in case or Unicode form count as the same
+	if g.nameTaken(name) {
+		return nil, &FieldError{Field: "playerName", Reason: "is already taken", Err: ErrPlayerNameTaken}
 	}
 
 	isHost := len(g.Players) == 0
-	g.Players[playerID] = &Player{
+	player := &Player{
 		ID:      playerID,
 		Name:    name,
 		IsAlive: true,
 		IsHost:  isHost,
+		Token:   newToken(),
 	}
+	g.Players[playerID] = player
 
-	return nil
+	return player, nil
 }
 
-func (g *Game) RemovePlayer(id string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	delete(g.Players, id)
-}
+// removePlayer takes a player out of the lobby. Started games keep every
+// player, since night actions and votes may still point at them.
+func (g *Game) removePlayer(playerID string) error {
+	if _, exists := g.Players[playerID]; !exists {
+		return ErrPlayerNotFound
+	}
+	if g.Phase != PhaseWaiting {
+		return ErrGameAlreadyStarted
+	}
 
-func (g *Game) IsGameReady() bool {
-	return len(g.Players) >= g.MinPlayers
+	delete(g.Players, playerID)
+	return nil
 }
 
-func (g *Game) GetAlivePlayers() []*Player {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-
-	alive := make([]*Player, 0)
-	for _, p := range g.Players {
-		if p.IsAlive {
-			alive = append(alive, p)
-		}
+// addBot seats a bot with the given ID in the lobby on behalf of the host.
+func (g *Game) addBot(hostID, botID string) error {
+	host, exists := g.Players[hostID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if !host.IsHost {
+		return ErrNotHost
+	}
+	if g.Phase != PhaseWaiting {
+		return ErrGameAlreadyStarted
 	}
-	return alive
-}
-
-func (g *Game) CheckWinCondition() (bool, Role) {
-	aliveVillains := 0
-	aliveCivilians := 0
 
-	for _, p := range g.Players {
-		if !p.IsAlive {
-			continue
-		}
-		if p.Role == RoleMafia {
-			aliveVillains++
-		} else {
-			aliveCivilians++
+	// Bots are numbered by the first name no one has taken yet
+	name := ""
+	for n := 1; name == ""; n++ {
+		name = fmt.Sprintf("Bot %d", n)
+		if g.nameTaken(name) {
+			name = ""
 		}
 	}
 
-	if aliveVillains == 0 {
-		return true, RoleVillager
-	}
-	if aliveVillains >= aliveCivilians {
-		return true, RoleMafia
+	bot, err := g.addPlayer(name, botID)
+	if err != nil {
+		return err
 	}
+	bot.IsBot = true
 
-	return false, ""
+	return nil
 }
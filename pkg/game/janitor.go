@@ -0,0 +1,206 @@
+package game
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// Reasons a game is closed by the server.
+const (
+	CloseReasonExpired  = "expired"
+	CloseReasonFinished = "finished"
+	// CloseReasonAbandoned is used when every player of a game in progress
+	// has left.
+	CloseReasonAbandoned = "abandoned"
+	// CloseReasonTerminated is used when an administrator ends a game.
+	CloseReasonTerminated = "terminated"
+)
+
+// JanitorConfig controls how often stale games are swept and how long they
+// may linger.
+type JanitorConfig struct {
+	// Interval is the time between sweeps.
+	Interval time.Duration
+	// WaitingTTL is how long a lobby may sit idle before it expires.
+	WaitingTTL time.Duration
+	// GameOverGrace is how long a finished game stays around so players can
+	// see the result before it is archived and removed.
+	GameOverGrace time.Duration
+	// AbandonedTTL is how long a game in progress may go without a
+	// connected player before it is closed. It needs WithConnectedPlayers.
+	AbandonedTTL time.Duration
+}
+
+// DefaultJanitorConfig returns the sweep settings used by the server.
+func DefaultJanitorConfig() JanitorConfig {
+	return JanitorConfig{
+		Interval:      time.Minute,
+		WaitingTTL:    30 * time.Minute,
+		GameOverGrace: 10 * time.Minute,
+		AbandonedTTL:  10 * time.Minute,
+	}
+}
+
+// Archive keeps the final state of finished games.
+type Archive interface {
+	ArchiveGame(snapshot *Snapshot) error
+}
+
+// DefaultArchiveCapacity is the number of finished games the server keeps
+// in memory.
+const DefaultArchiveCapacity = 1000
+
+// MemoryArchive is an Archive that keeps the most recent finished games in
+// memory, dropping the oldest once it is full.
+type MemoryArchive struct {
+	games []*Snapshot
+	// next is where the next game goes once the archive is full.
+	next int
+	mu   sync.RWMutex
+}
+
+// NewMemoryArchive returns an archive that holds at most capacity games.
+func NewMemoryArchive(capacity int) *MemoryArchive {
+	return &MemoryArchive{
+		games: make([]*Snapshot, 0, max(capacity, 1)),
+	}
+}
+
+func (a *MemoryArchive) ArchiveGame(snapshot *Snapshot) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.games) < cap(a.games) {
+		a.games = append(a.games, snapshot)
+		return nil
+	}
+	a.games[a.next] = snapshot
+	a.next = (a.next + 1) % len(a.games)
+	return nil
+}
+
+// Games returns the archived games, oldest first.
+func (a *MemoryArchive) Games() []*Snapshot {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	games := make([]*Snapshot, 0, len(a.games))
+	games = append(games, a.games[a.next:]...)
+	return append(games, a.games[:a.next]...)
+}
+
+// Janitor removes abandoned lobbies and games, and finished games, from a
+// GameManager.
+type Janitor struct {
+	manager *GameManager
+	archive Archive
+	config  JanitorConfig
+	// connected counts the players connected to a game. Without it games
+	// in progress are never considered abandoned.
+	connected func(gameID string) int
+	// emptySince is when each game in progress was first swept without a
+	// connected player.
+	emptySince map[string]time.Time
+	mu         sync.Mutex
+}
+
+// JanitorOption configures a Janitor.
+type JanitorOption func(*Janitor)
+
+// WithConnectedPlayers lets the janitor close games in progress that have
+// had no connected players for JanitorConfig.AbandonedTTL. count returns the
+// number of players connected to a game.
+func WithConnectedPlayers(count func(gameID string) int) JanitorOption {
+	return func(j *Janitor) {
+		j.connected = count
+	}
+}
+
+func NewJanitor(manager *GameManager, archive Archive, config JanitorConfig, opts ...JanitorOption) *Janitor {
+	j := &Janitor{
+		manager:    manager,
+		archive:    archive,
+		config:     config,
+		emptySince: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Run sweeps on every interval, as measured by the manager's clock, until
+// the context is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	for {
+		timer := j.manager.clock.NewTimer(j.config.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C():
+			j.Sweep(now)
+		}
+	}
+}
+
+// Sweep expires lobbies idle for longer than the TTL, closes games in
+// progress that have gone without connected players for longer than theirs,
+// and archives and removes games that have been over for longer than the
+// grace period.
+func (j *Janitor) Sweep(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	inProgress := make(map[string]bool)
+	for _, snapshot := range j.manager.Snapshots() {
+		switch snapshot.Phase {
+		case PhaseWaiting:
+			if now.Sub(snapshot.UpdatedAt) > j.config.WaitingTTL {
+				j.manager.logger.Info("lobby expired", logging.KeyGameID, snapshot.ID, "idle_since", snapshot.UpdatedAt)
+				j.manager.CloseGame(snapshot.ID, CloseReasonExpired)
+			}
+
+		case PhaseGameOver:
+			if snapshot.Result == nil || now.Sub(snapshot.Result.EndedAt) <= j.config.GameOverGrace {
+				continue
+			}
+			if j.archive != nil {
+				if err := j.archive.ArchiveGame(snapshot); err != nil {
+					j.manager.logger.Error("archiving game failed", logging.KeyGameID, snapshot.ID, "error", err)
+					continue
+				}
+			}
+			j.manager.CloseGame(snapshot.ID, CloseReasonFinished)
+
+		default:
+			if j.connected == nil {
+				continue
+			}
+			if j.connected(snapshot.ID) > 0 {
+				continue
+			}
+			since, seen := j.emptySince[snapshot.ID]
+			if !seen {
+				since = now
+				j.emptySince[snapshot.ID] = now
+			}
+			if now.Sub(since) > j.config.AbandonedTTL {
+				j.manager.logger.Info("game abandoned", logging.KeyGameID, snapshot.ID, "empty_since", since)
+				j.manager.CloseGame(snapshot.ID, CloseReasonAbandoned)
+				continue
+			}
+			inProgress[snapshot.ID] = true
+		}
+	}
+
+	// Forget games that were closed, ended or got a player back
+	for gameID := range j.emptySince {
+		if !inProgress[gameID] {
+			delete(j.emptySince, gameID)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMemoryArchiveDropsOldest checks that a full archive keeps only the
+// most recent games, oldest first.
+func TestMemoryArchiveDropsOldest(t *testing.T) {
+	archive := NewMemoryArchive(3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := archive.ArchiveGame(&Snapshot{State: State{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	games := archive.Games()
+	var ids []string
+	for _, g := range games {
+		ids = append(ids, g.ID)
+	}
+	if len(ids) != 3 || ids[0] != "c" || ids[1] != "d" || ids[2] != "e" {
+		t.Fatalf("archived %v, want [c d e]", ids)
+	}
+}
+
+// TestSweepClosesAbandonedGames checks that a game in progress is closed
+// once no player has been connected to it for the TTL, and that games with
+// a player connected are kept.
+func TestSweepClosesAbandonedGames(t *testing.T) {
+	m := newTestManager()
+	var abandoned, played string
+	for _, id := range []*string{&abandoned, &played} {
+		created, err := m.CreateGame(GameOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"ann", "ben", "cal", "dee"} {
+			if err := m.AddPlayer(created.ID, name, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := m.StartGame(created.ID); err != nil {
+			t.Fatal(err)
+		}
+		*id = created.ID
+	}
+
+	var events []Event
+	m.Subscribe(func(event Event) {
+		if event.Type == EventGameRemoved || event.Type == EventGameClosed {
+			events = append(events, event)
+		}
+	})
+
+	config := DefaultJanitorConfig()
+	janitor := NewJanitor(m, nil, config, WithConnectedPlayers(func(gameID string) int {
+		if gameID == played {
+			return 1
+		}
+		return 0
+	}))
+
+	start := m.Clock().Now()
+	steps := []struct {
+		after time.Duration
+		open  bool
+	}{
+		{0, true},
+		{config.AbandonedTTL, true},
+		{config.AbandonedTTL + config.Interval, false},
+	}
+	for _, step := range steps {
+		janitor.Sweep(start.Add(step.after))
+		if _, err := m.GetGame(abandoned); (err == nil) != step.open {
+			t.Fatalf("after %v: abandoned game open = %v, want %v", step.after, err == nil, step.open)
+		}
+		if _, err := m.GetGame(played); err != nil {
+			t.Fatalf("after %v: game with a connected player was closed", step.after)
+		}
+	}
+
+	if len(events) != 2 || events[0].Type != EventGameRemoved || events[1].Type != EventGameClosed || events[1].Reason != CloseReasonAbandoned {
+		t.Fatalf("events %v, want the game removed and then closed as abandoned", events)
+	}
+}
+
+// TestRemoveGameForgetsBots checks that removing a game, closed or not,
+// drops the strategies of its bots.
+func TestRemoveGameForgetsBots(t *testing.T) {
+	m := newTestManager()
+	bots := NewBots(m, BotConfig{})
+	created, err := m.CreateGame(GameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddPlayer(created.ID, "ann", "ann"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bots.Add(created.ID, "ann", RandomStrategy{}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.RemoveGame(created.ID)
+
+	bots.mu.Lock()
+	defer bots.mu.Unlock()
+	if _, exists := bots.strategies[created.ID]; exists {
+		t.Fatal("bot strategies kept after the game was removed")
+	}
+}
@@ -0,0 +1,125 @@
+package game
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type Visibility string
+
+const (
+	// VisibilityPrivate games can only be joined by players who know the ID.
+	VisibilityPrivate Visibility = "private"
+	// VisibilityPublic games are listed in the lobby.
+	VisibilityPublic Visibility = "public"
+)
+
+// MaxGameNameLength is the longest name a game can be given.
+const MaxGameNameLength = 40
+
+// Default and maximum page sizes for ListGames.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// GameSummary is the lobby listing entry for a game.
+type GameSummary struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name,omitempty"`
+	Visibility  Visibility   `json:"visibility"`
+	HasPassword bool         `json:"hasPassword"`
+	Phase       Phase        `json:"phase"`
+	Host        string       `json:"host,omitempty"`
+	PlayerCount int          `json:"playerCount"`
+	MaxPlayers  int          `json:"maxPlayers"`
+	Settings    GameSettings `json:"settings"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+// Summary returns the lobby listing entry for the game.
+func (s *Snapshot) Summary() GameSummary {
+	summary := GameSummary{
+		ID:          s.ID,
+		Name:        s.Name,
+		Visibility:  s.Visibility,
+		HasPassword: s.HasPassword,
+		Phase:       s.Phase,
+		PlayerCount: len(s.Players),
+		MaxPlayers:  s.MaxPlayers,
+		Settings:    s.GameSettings,
+		CreatedAt:   s.CreatedAt,
+	}
+	for _, p := range s.Players {
+		if p.IsHost {
+			summary.Host = p.Name
+		}
+	}
+	return summary
+}
+
+// ListFilter selects and paginates the games returned by ListGames. Zero
+// values match every game.
+type ListFilter struct {
+	Visibility Visibility
+	Phase      Phase
+	// Query matches games whose name contains it, ignoring case.
+	Query string
+	// OpenOnly excludes games that are full.
+	OpenOnly bool
+	Offset   int
+	Limit    int
+}
+
+// ListGames returns one page of the games matching the filter, newest first,
+// along with the total number of matches.
+func (m *GameManager) ListGames(filter ListFilter) ([]GameSummary, int) {
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+
+	matches := make([]GameSummary, 0)
+	for _, snapshot := range m.Snapshots() {
+		if filter.Visibility != "" && snapshot.Visibility != filter.Visibility {
+			continue
+		}
+		if filter.Phase != "" && snapshot.Phase != filter.Phase {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(snapshot.Name), query) {
+			continue
+		}
+		if filter.OpenOnly && len(snapshot.Players) >= snapshot.MaxPlayers {
+			continue
+		}
+		matches = append(matches, snapshot.Summary())
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].ID < matches[j].ID
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	total := len(matches)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return matches[offset:end], total
+}
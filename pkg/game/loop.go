@@ -0,0 +1,205 @@
+package game
+
+import (
+	"time"
+
+	"github.com/silent-vendetta/pkg/clock"
+)
+
+// command is a unit of work applied by the game's goroutine.
+type command struct {
+	apply func(g *Game) error
+	reply chan error
+}
+
+type EventType string
+
+const (
+	// EventPhaseChanged is published whenever a game moves to a new phase or
+	// round, whether by command or because a phase timer expired.
+	EventPhaseChanged EventType = "phaseChanged"
+	// EventPlayersChanged is published when players join or leave a game.
+	EventPlayersChanged EventType = "playersChanged"
+	// EventGameCreated is published when a new lobby is opened.
+	EventGameCreated EventType = "gameCreated"
+	// EventGameClosed is published when the server closes a game, after
+	// its EventGameRemoved, to tell its players why.
+	EventGameClosed EventType = "gameClosed"
+	// EventGameRemoved is published whenever a game is removed, closed or
+	// not, so listeners can forget it.
+	EventGameRemoved EventType = "gameRemoved"
+)
+
+// Event describes a state change of a game. Listeners run on the game's
+// goroutine and must not wait for commands on the same game to complete.
+type Event struct {
+	Type     EventType
+	GameID   string
+	Snapshot *Snapshot
+	// Reason explains why a game was closed.
+	Reason string
+}
+
+// Snapshot is an immutable copy of a game's state, published after every
+// command. It is safe to read and serialize from any goroutine, but must not
+// be modified.
+type Snapshot struct {
+	State
+	// seed and soloWinners are kept out of the state sent to clients, but
+	// are needed to save the game.
+	seed        int64
+	soloWinners []string
+}
+
+// IsGameReady reports whether enough players have joined to start.
+func (s *Snapshot) IsGameReady() bool {
+	return len(s.Players) >= s.MinPlayers
+}
+
+// AlivePlayers returns the players that are still alive.
+func (s *Snapshot) AlivePlayers() []*Player {
+	alive := make([]*Player, 0)
+	for _, p := range s.Players {
+		if p.IsAlive {
+			alive = append(alive, p)
+		}
+	}
+	return alive
+}
+
+// PlayerInvestigations returns everything the player has learned from
+// investigations.
+func (s *Snapshot) PlayerInvestigations(playerID string) []Investigation {
+	if p, exists := s.Players[playerID]; exists {
+		return p.Investigations
+	}
+	return nil
+}
+
+// run is the game's goroutine. It is the only code that touches the game
+// state: it applies commands in order and advances the phase when its timer
+// expires.
+func (g *Game) run() {
+	var timer clock.Timer
+	var timerC <-chan time.Time
+	var armed time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		if deadline := g.deadline(); !deadline.Equal(armed) {
+			if timer != nil {
+				timer.Stop()
+			}
+			timer, timerC, armed = nil, nil, deadline
+			if !deadline.IsZero() {
+				timer = g.clock.NewTimer(deadline.Sub(g.clock.Now()))
+				timerC = timer.C()
+			}
+		}
+
+		select {
+		case cmd := <-g.cmds:
+			g.apply(cmd)
+		case <-timerC:
+			timer, timerC, armed = nil, nil, time.Time{}
+			g.apply(command{apply: func(g *Game) error {
+				g.tick(g.clock.Now())
+				return nil
+			}})
+		case <-g.done:
+			return
+		}
+	}
+}
+
+// apply runs a command, publishes the resulting snapshot and notifies
+// listeners if the phase or the players changed.
+func (g *Game) apply(cmd command) {
+	phase, round, players := g.Phase, g.Round, len(g.Players)
+	err := cmd.apply(g)
+	g.UpdatedAt = g.clock.Now()
+	g.publish()
+	if cmd.reply != nil {
+		cmd.reply <- err
+	}
+
+	if g.notify == nil {
+		return
+	}
+	if g.Phase != phase || g.Round != round {
+		g.notify(Event{Type: EventPhaseChanged, GameID: g.ID, Snapshot: g.Snapshot()})
+	}
+	if len(g.Players) != players {
+		g.notify(Event{Type: EventPlayersChanged, GameID: g.ID, Snapshot: g.Snapshot()})
+	}
+}
+
+// do sends a command to the game's goroutine and waits for its result.
+func (g *Game) do(fn func(g *Game) error) error {
+	reply := make(chan error, 1)
+	select {
+	case g.cmds <- command{apply: fn, reply: reply}:
+		return <-reply
+	case <-g.done:
+		return ErrGameNotFound
+	}
+}
+
+// stop terminates the game's goroutine. Pending and later commands fail with
+// ErrGameNotFound.
+func (g *Game) stop() {
+	close(g.done)
+}
+
+// deadline returns when the current phase timer expires, or the zero time if
+// the current phase is not timed.
+func (g *Game) deadline() time.Time {
+	switch g.Phase {
+	case PhaseNight, PhaseDiscuss, PhaseVote:
+		return g.PhaseEndTime
+	}
+	return time.Time{}
+}
+
+// tick advances the phase if its timer has expired.
+func (g *Game) tick(now time.Time) {
+	if deadline := g.deadline(); !deadline.IsZero() && !now.Before(deadline) {
+		g.advancePhase()
+	}
+}
+
+// Snapshot returns the most recently published state of the game.
+func (g *Game) Snapshot() *Snapshot {
+	return g.snapshot.Load()
+}
+
+// publish stores a deep copy of the current state as the latest snapshot.
+func (g *Game) publish() {
+	g.snapshot.Store(&Snapshot{
+		State:       g.State.clone(),
+		seed:        g.Seed,
+		soloWinners: append([]string(nil), g.soloWinners...),
+	})
+}
+
+func (s *State) clone() State {
+	c := *s
+	c.Players = make(map[string]*Player, len(s.Players))
+	for id, p := range s.Players {
+		player := *p
+		player.Investigations = append([]Investigation(nil), p.Investigations...)
+		c.Players[id] = &player
+	}
+	c.Deaths = append([]Death(nil), s.Deaths...)
+	if s.Result != nil {
+		result := *s.Result
+		result.Factions = append([]Faction(nil), s.Result.Factions...)
+		result.Winners = append([]Winner(nil), s.Result.Winners...)
+		c.Result = &result
+	}
+	return c
+}
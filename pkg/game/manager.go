@@ -1,361 +1,471 @@
 package game
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
-	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/silent-vendetta/pkg/clock"
+	"github.com/silent-vendetta/pkg/logging"
+	"golang.org/x/text/unicode/norm"
 )
 
 type GameManager struct {
-	games map[string]*Game
-	mu    sync.RWMutex
+	games     map[string]*Game
+	codes     map[string]string
+	seeder    Seeder
+	newSource SourceFactory
+	listeners []func(Event)
+	throttle  *passwordThrottle
+	archive   Archive
+	clock     clock.Clock
+	logger    *slog.Logger
+	settings  GameSettings
+	maxGames  int
+	maxOwned  int
+	mu        sync.RWMutex
 }
 
-func NewGameManager() *GameManager {
-	return &GameManager{
-		games: make(map[string]*Game),
+// Option configures a GameManager.
+type Option func(*GameManager)
+
+// WithSeeder overrides how per-game shuffle seeds are generated.
+func WithSeeder(seeder Seeder) Option {
+	return func(m *GameManager) {
+		m.seeder = seeder
 	}
 }
 
-func (m *GameManager) CreateGame() (*Game, error) {
-	gameID := uuid.New().String()
-	game := NewGame(gameID)
+// WithSourceFactory overrides how the shuffle source is built from a seed.
+func WithSourceFactory(factory SourceFactory) Option {
+	return func(m *GameManager) {
+		m.newSource = factory
+	}
+}
 
-	m.mu.Lock()
-	m.games[gameID] = game
-	m.mu.Unlock()
+// WithArchive keeps the result of every match that ends in a rematch.
+func WithArchive(archive Archive) Option {
+	return func(m *GameManager) {
+		m.archive = archive
+	}
+}
 
-	return game, nil
+// WithClock sets the clock used for phase timers and every other timed
+// decision about the manager's games.
+func WithClock(c clock.Clock) Option {
+	return func(m *GameManager) {
+		m.clock = c
+	}
 }
 
-func (m *GameManager) GetGame(id string) (*Game, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// WithLogger sets the logger the manager and its games write to.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *GameManager) {
+		m.logger = logger
+	}
+}
 
-	game, exists := m.games[id]
-	if !exists {
-		return nil, ErrGameNotFound
+// WithDefaultSettings sets the settings new lobbies start with.
+func WithDefaultSettings(settings GameSettings) Option {
+	return func(m *GameManager) {
+		m.settings = settings
 	}
+}
 
-	return game, nil
+// WithMaxGames caps the number of games that can exist at once. Zero means
+// no limit.
+func WithMaxGames(n int) Option {
+	return func(m *GameManager) {
+		m.maxGames = n
+	}
 }
 
-func (m *GameManager) StartGame(gameID string) error {
-	game, err := m.GetGame(gameID)
-	if err != nil {
-		return err
+// WithMaxGamesPerOwner caps the number of games the same GameOptions.Owner
+// can have open at once. Zero means no limit.
+func WithMaxGamesPerOwner(n int) Option {
+	return func(m *GameManager) {
+		m.maxOwned = n
 	}
+}
+
+func NewGameManager(opts ...Option) *GameManager {
+	m := &GameManager{
+		games:     make(map[string]*Game),
+		codes:     make(map[string]string),
+		seeder:    CryptoSeeder,
+		newSource: DefaultSourceFactory,
+		throttle:  newPasswordThrottle(),
+		clock:     clock.Real{},
+		logger:    logging.Default(),
+		settings:  DefaultSettings(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Clock returns the clock that drives the manager's games.
+func (m *GameManager) Clock() clock.Clock {
+	return m.clock
+}
 
-	if !game.IsGameReady() {
-		return ErrNotEnoughPlayers
+// Subscribe registers fn to be called for every game event.
+func (m *GameManager) Subscribe(fn func(Event)) {
+	m.mu.Lock()
+	m.listeners = append(m.listeners, fn)
+	m.mu.Unlock()
+}
+
+func (m *GameManager) publish(event Event) {
+	m.mu.RLock()
+	listeners := m.listeners
+	m.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(event)
 	}
+}
+
+// GameOptions describes a lobby when it is created.
+type GameOptions struct {
+	// Name is an optional display name for the lobby.
+	Name string
+	// Visibility defaults to VisibilityPrivate.
+	Visibility Visibility
+	// Password, if set, must be given by every player who joins.
+	Password string
+	// Owner identifies who is opening the lobby, such as their IP address,
+	// for WithMaxGamesPerOwner. It is never shown to players.
+	Owner string
+}
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
+// Validate reports every invalid option, as *FieldErrors joined together.
+func (o GameOptions) Validate() error {
+	var errs []error
 
-	if game.Phase != PhaseWaiting {
-		return ErrGameAlreadyStarted
+	name := norm.NFKC.String(strings.TrimSpace(o.Name))
+	if len(o.Name) > maxNameBytes || utf8.RuneCountInString(name) > MaxGameNameLength {
+		errs = append(errs, &FieldError{Field: "name", Reason: fmt.Sprintf("must be at most %d characters", MaxGameNameLength), Err: ErrInvalidGameName})
+	} else if strings.IndexFunc(name, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		errs = append(errs, &FieldError{Field: "name", Reason: "must not contain control characters", Err: ErrInvalidGameName})
 	}
 
-	// Assign roles
-	players := make([]*Player, 0, len(game.Players))
-	for _, p := range game.Players {
-		log.Printf("Adding player to role assignment: %s", p.Name)
-		players = append(players, p)
+	switch o.Visibility {
+	case "", VisibilityPrivate, VisibilityPublic:
+	default:
+		errs = append(errs, &FieldError{Field: "visibility", Reason: fmt.Sprintf("must be %q or %q", VisibilityPrivate, VisibilityPublic), Err: ErrInvalidVisibility})
 	}
 
-	// Shuffle players
-	for i := len(players) - 1; i > 0; i-- {
-		j := int(time.Now().UnixNano()) % (i + 1)
-		players[i], players[j] = players[j], players[i]
+	if len(o.Password) > MaxPasswordLength {
+		errs = append(errs, &FieldError{Field: "password", Reason: fmt.Sprintf("must be at most %d bytes", MaxPasswordLength), Err: ErrPasswordTooLong})
 	}
 
-	// Assign mafias
-	mafiaCount := game.MafiaCount
-	if mafiaCount > len(players)/3 {
-		mafiaCount = len(players) / 3
+	return errors.Join(errs...)
+}
+
+// CreateGame creates a new lobby.
+func (m *GameManager) CreateGame(opts GameOptions) (*Snapshot, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
-	for i := 0; i < mafiaCount; i++ {
-		players[i].Role = RoleMafia
-		log.Printf("Assigned Mafia role to: %s", players[i].Name)
+	name := norm.NFKC.String(strings.TrimSpace(opts.Name))
+	visibility := opts.Visibility
+	if visibility == "" {
+		visibility = VisibilityPrivate
 	}
 
-	// Assign special roles (detective and medic)
-	currentIndex := mafiaCount
-	if len(players) >= 5 {
-		players[currentIndex].Role = RoleDetective
-		log.Printf("Assigned Detective role to: %s", players[currentIndex].Name)
-		currentIndex++
-
-		if len(players) >= 7 {
-			players[currentIndex].Role = RoleMedic
-			log.Printf("Assigned Medic role to: %s", players[currentIndex].Name)
-			currentIndex++
+	var passwordHash []byte
+	if opts.Password != "" {
+		hash, err := hashPassword(opts.Password)
+		if err != nil {
+			return nil, err
 		}
+		passwordHash = hash
 	}
 
-	// Assign remaining players as villagers
-	for i := currentIndex; i < len(players); i++ {
-		players[i].Role = RoleVillager
-		log.Printf("Assigned Villager role to: %s", players[i].Name)
-	}
-
-	game.Phase = PhaseNight
-	game.Round = 1
-	game.PhaseEndTime = time.Now().Add(30 * time.Second)
+	gameID := uuid.New().String()
 
-	// Log final role assignments
-	log.Printf("Final role assignments:")
-	for _, p := range players {
-		log.Printf("Player %s - Role: %s", p.Name, p.Role)
+	m.mu.Lock()
+	if m.maxGames > 0 && len(m.games) >= m.maxGames {
+		m.mu.Unlock()
+		return nil, ErrTooManyGames
 	}
-
-	return nil
-}
-
-func (m *GameManager) HandleVote(gameID string, voterID string, targetID string) error {
-	game, err := m.GetGame(gameID)
+	if m.maxOwned > 0 && opts.Owner != "" && m.ownedBy(opts.Owner) >= m.maxOwned {
+		m.mu.Unlock()
+		return nil, ErrTooManyOwnGames
+	}
+	code, err := m.allocateCode(gameID)
 	if err != nil {
-		return err
+		m.mu.Unlock()
+		return nil, err
 	}
+	info := State{ID: gameID, Code: code, Name: name, Visibility: visibility, GameSettings: m.settings}
+	game := newGame(info, passwordHash, m.clock, m.logger, m.publish)
+	game.owner = opts.Owner
+	m.games[gameID] = game
+	m.mu.Unlock()
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
+	snapshot := game.Snapshot()
+	m.publish(Event{Type: EventGameCreated, GameID: gameID, Snapshot: snapshot})
+	return snapshot, nil
+}
 
-	if game.Phase != PhaseVote {
-		return ErrInvalidPhase
+// ownedBy counts the open games opened by owner. m.mu must be held.
+func (m *GameManager) ownedBy(owner string) int {
+	n := 0
+	for _, game := range m.games {
+		if game.owner == owner {
+			n++
+		}
 	}
+	return n
+}
 
-	voter, exists := game.Players[voterID]
+func (m *GameManager) game(id string) (*Game, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	game, exists := m.games[id]
 	if !exists {
-		return ErrPlayerNotFound
+		return nil, ErrGameNotFound
 	}
 
-	if !voter.IsAlive {
-		return ErrPlayerNotAlive
-	}
+	return game, nil
+}
 
-	target, exists := game.Players[targetID]
-	if !exists {
-		return ErrPlayerNotFound
+// GetGame returns the latest snapshot of a game's state
+func (m *GameManager) GetGame(id string) (*Snapshot, error) {
+	game, err := m.game(id)
+	if err != nil {
+		return nil, err
 	}
 
-	if !target.IsAlive {
-		return ErrPlayerNotAlive
+	return game.Snapshot(), nil
+}
+
+// withGame runs fn as a command on the game's goroutine and waits for it to
+// complete. fn must only call the rule methods on *Game.
+func (m *GameManager) withGame(gameID string, fn func(game *Game) error) error {
+	game, err := m.game(gameID)
+	if err != nil {
+		return err
 	}
 
-	voter.VotedFor = targetID
-	return nil
+	return game.do(fn)
 }
 
-func (m *GameManager) ProcessVotes(gameID string) (string, error) {
-	game, err := m.GetGame(gameID)
+// AddPlayer seats a player in a lobby under the normalized form of name. It
+// fails with a *FieldError if the name is invalid or already taken.
+func (m *GameManager) AddPlayer(gameID string, name string, playerID string) error {
+	_, err := m.SeatPlayer(gameID, name, playerID)
+	return err
+}
+
+// SeatPlayer seats a player like AddPlayer and returns the secret token the
+// player proves their seat with, see Authenticate.
+func (m *GameManager) SeatPlayer(gameID string, name string, playerID string) (string, error) {
+	name, err := NormalizePlayerName(name)
 	if err != nil {
 		return "", err
 	}
+	var token string
+	err = m.withGame(gameID, func(game *Game) error {
+		player, err := game.addPlayer(name, playerID)
+		if err != nil {
+			return err
+		}
+		token = player.Token
+		return nil
+	})
+	return token, err
+}
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
+// AddBot seats a bot player with the given ID in a lobby. Only the host can
+// add bots; they are driven by Bots.
+func (m *GameManager) AddBot(gameID string, hostID string, botID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.addBot(hostID, botID)
+	})
+}
 
-	votes := make(map[string]int)
-	for _, player := range game.Players {
-		if player.IsAlive && player.VotedFor != "" {
-			votes[player.VotedFor]++
-		}
-	}
+// RemovePlayer takes a player out of a lobby. Once the game has started
+// players can only forfeit.
+func (m *GameManager) RemovePlayer(gameID string, playerID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.removePlayer(playerID)
+	})
+}
 
-	maxVotes := 0
-	var eliminated string
-	for playerID, voteCount := range votes {
-		if voteCount > maxVotes {
-			maxVotes = voteCount
-			eliminated = playerID
-		}
-	}
+func (m *GameManager) StartGame(gameID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		seed := m.seeder()
+		return game.start(seed, m.newSource(seed))
+	})
+}
 
-	if eliminated != "" {
-		game.Players[eliminated].IsAlive = false
-	}
+// StartGameAsHost starts a game on behalf of one of its players, who must be
+// the host.
+func (m *GameManager) StartGameAsHost(gameID string, playerID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		seed := m.seeder()
+		return game.startAsHost(playerID, seed, m.newSource(seed))
+	})
+}
 
-	// Reset votes
-	for _, player := range game.Players {
-		player.VotedFor = ""
-	}
+func (m *GameManager) HandleVote(gameID string, voterID string, targetID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.vote(voterID, targetID)
+	})
+}
 
-	return eliminated, nil
+// ProcessVotes eliminates the player with the most day votes and returns their ID
+func (m *GameManager) ProcessVotes(gameID string) (string, error) {
+	var eliminated string
+	err := m.withGame(gameID, func(game *Game) error {
+		eliminated = game.tallyVotes()
+		return nil
+	})
+	return eliminated, err
 }
 
+// RemoveGame stops a game and forgets it, notifying listeners with
+// EventGameRemoved.
 func (m *GameManager) RemoveGame(id string) {
 	m.mu.Lock()
+	game, exists := m.games[id]
+	if exists {
+		delete(m.codes, game.Code)
+	}
 	delete(m.games, id)
 	m.mu.Unlock()
+
+	if exists {
+		game.stop()
+		m.throttle.forget(id)
+		m.publish(Event{Type: EventGameRemoved, GameID: id, Snapshot: game.Snapshot()})
+	}
 }
 
-// HandleMafiaAction records a mafia member's target for the night
-func (m *GameManager) HandleMafiaAction(gameID string, mafiaID string, targetID string) error {
-	game, err := m.GetGame(gameID)
+// CloseGame removes a game and notifies listeners why it was closed.
+func (m *GameManager) CloseGame(id string, reason string) {
+	snapshot, err := m.GetGame(id)
 	if err != nil {
-		return err
+		return
 	}
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
-
-	if game.Phase != PhaseNight {
-		return ErrInvalidPhase
-	}
+	m.RemoveGame(id)
+	m.publish(Event{Type: EventGameClosed, GameID: id, Snapshot: snapshot, Reason: reason})
+}
 
-	mafia, exists := game.Players[mafiaID]
-	if !exists {
-		return ErrPlayerNotFound
-	}
+// Snapshots returns the latest snapshot of every game.
+func (m *GameManager) Snapshots() []*Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if mafia.Role != RoleMafia {
-		return ErrNotMafia
+	snapshots := make([]*Snapshot, 0, len(m.games))
+	for _, game := range m.games {
+		snapshots = append(snapshots, game.Snapshot())
 	}
+	return snapshots
+}
 
-	target, exists := game.Players[targetID]
-	if !exists {
-		return ErrPlayerNotFound
-	}
+// HandleMafiaAction records a mafia member's target for the night
+func (m *GameManager) HandleMafiaAction(gameID string, mafiaID string, targetID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.mafiaAction(mafiaID, targetID)
+	})
+}
 
-	if !target.IsAlive {
-		return ErrPlayerNotAlive
-	}
+// ProcessNightActions resolves all night actions (roleblocks, medic saves,
+// kills and detective investigations) in pipeline order
+func (m *GameManager) ProcessNightActions(gameID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		game.resolveNight()
+		return nil
+	})
+}
 
-	// Record the mafia's vote
-	mafia.VotedFor = targetID
-	log.Printf("Mafia %s voted to kill %s", mafia.Name, target.Name)
+// HandleNightAction records the target of a role's night action
+func (m *GameManager) HandleNightAction(gameID string, playerID string, targetID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.nightAction(playerID, targetID)
+	})
+}
 
-	return nil
+// RevealMayor publicly reveals a living mayor during the day, after which
+// their vote counts triple
+func (m *GameManager) RevealMayor(gameID string, playerID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.revealMayor(playerID)
+	})
 }
 
-// ProcessNightActions processes all night actions (mafia kills, detective investigations, medic saves)
-func (m *GameManager) ProcessNightActions(gameID string) error {
-	game, err := m.GetGame(gameID)
-	if err != nil {
-		return err
-	}
+// ForfeitPlayer removes a player who abandons the game. Before the game starts
+// the player simply leaves the lobby; afterwards they are counted as dead.
+func (m *GameManager) ForfeitPlayer(gameID string, playerID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.forfeit(playerID)
+	})
+}
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
-
-	// Count mafia votes
-	votes := make(map[string]int)
-	var mafiaMembers int
-	for _, player := range game.Players {
-		if player.IsAlive && player.Role == RoleMafia {
-			mafiaMembers++
-			if player.VotedFor != "" {
-				votes[player.VotedFor]++
-			}
-		}
-	}
+// UpdateSettings changes the settings of a game that has not started yet
+func (m *GameManager) UpdateSettings(gameID string, settings GameSettings) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.updateSettings(settings)
+	})
+}
 
-	// Find the target with the most votes
-	var targetID string
-	maxVotes := 0
-	for id, count := range votes {
-		if count > maxVotes {
-			maxVotes = count
-			targetID = id
-		}
-	}
+// UpdateSettingsAsHost changes the settings of a game that has not started
+// yet on behalf of one of its players, who must be the host
+func (m *GameManager) UpdateSettingsAsHost(gameID string, playerID string, settings GameSettings) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.updateSettingsAsHost(playerID, settings)
+	})
+}
 
-	// Only kill if at least half of living mafia voted for the same target
-	if maxVotes >= (mafiaMembers+1)/2 && targetID != "" {
-		target := game.Players[targetID]
-		target.IsAlive = false
-		log.Printf("Mafia killed player %s", target.Name)
-	}
+// Rematch returns a finished game to the lobby, keeping its ID, settings and
+// players, so the host can start a new match
+func (m *GameManager) Rematch(gameID string, playerID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.rematch(playerID, m.archive)
+	})
+}
 
-	// Reset all votes
-	for _, player := range game.Players {
-		player.VotedFor = ""
-	}
+func (m *GameManager) AdvancePhase(gameID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		return game.advancePhase()
+	})
+}
 
-	return nil
+// Tick advances the game to its next phase if the phase timer has expired.
+// Games tick themselves; this lets callers force an early check.
+func (m *GameManager) Tick(gameID string) error {
+	return m.withGame(gameID, func(game *Game) error {
+		game.tick(game.clock.Now())
+		return nil
+	})
 }
 
-func (m *GameManager) AdvancePhase(gameID string) error {
-	game, err := m.GetGame(gameID)
+// CheckWinCondition evaluates the win conditions and returns the result of
+// the game, or nil if no side has won yet.
+func (m *GameManager) CheckWinCondition(gameID string) (*GameResult, error) {
+	err := m.withGame(gameID, func(game *Game) error {
+		game.evaluateWin()
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	game.mu.Lock()
-	defer game.mu.Unlock()
-
-	// Define phase durations
-	const (
-		NightDuration   = 30 * time.Second
-		DiscussDuration = 120 * time.Second
-		VoteDuration    = 30 * time.Second
-	)
-
-	// Transition to next phase
-	switch game.Phase {
-	case PhaseNight:
-		// Process night actions before moving to discussion
-		if err := m.ProcessNightActions(gameID); err != nil {
-			return err
-		}
-		game.Phase = PhaseDiscuss
-		game.PhaseEndTime = time.Now().Add(DiscussDuration)
-		log.Printf("Game %s: Night phase ended, moving to Discussion phase", gameID)
-
-	case PhaseDiscuss:
-		game.Phase = PhaseVote
-		game.PhaseEndTime = time.Now().Add(VoteDuration)
-		log.Printf("Game %s: Discussion phase ended, moving to Voting phase", gameID)
-
-	case PhaseVote:
-		// Process votes and eliminate player
-		eliminated, err := m.ProcessVotes(gameID)
-		if err != nil {
-			return err
-		}
-
-		if eliminated != "" {
-			log.Printf("Game %s: Player %s was eliminated", gameID, game.Players[eliminated].Name)
-		}
-
-		// Check win conditions
-		mafiaCount := 0
-		villagerCount := 0
-		for _, p := range game.Players {
-			if !p.IsAlive {
-				continue
-			}
-			if p.Role == RoleMafia {
-				mafiaCount++
-			} else {
-				villagerCount++
-			}
-		}
-
-		// Check if game is over
-		if mafiaCount == 0 {
-			game.Phase = PhaseGameOver
-			log.Printf("Game %s: Villagers win!", gameID)
-			return nil
-		} else if mafiaCount >= villagerCount {
-			game.Phase = PhaseGameOver
-			log.Printf("Game %s: Mafia wins!", gameID)
-			return nil
-		}
-
-		// If game isn't over, start next night phase
-		game.Phase = PhaseNight
-		game.Round++
-		game.PhaseEndTime = time.Now().Add(NightDuration)
-		log.Printf("Game %s: Starting night phase of round %d", gameID, game.Round)
-
-	default:
-		return ErrInvalidPhase
+	snapshot, err := m.GetGame(gameID)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return snapshot.Result, nil
 }
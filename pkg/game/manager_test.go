@@ -0,0 +1,209 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentGames plays several games at once while every player acts,
+// readers poll snapshots and the phase is advanced, all from their own
+// goroutines. Run it with -race.
+func TestConcurrentGames(t *testing.T) {
+	const (
+		games       = 8
+		readers     = 2
+		maxAdvances = 2000
+	)
+	players := []string{"ann", "ben", "cal", "dee", "eve", "fay", "gus"}
+
+	m := newTestManager()
+	var wg sync.WaitGroup
+	for i := 0; i < games; i++ {
+		created, err := m.CreateGame(GameOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gameID := created.ID
+		for _, name := range players {
+			if err := m.AddPlayer(gameID, name, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := m.StartGame(gameID); err != nil {
+			t.Fatal(err)
+		}
+
+		for j, name := range players {
+			wg.Add(1)
+			go func(name string, r *rand.Rand) {
+				defer wg.Done()
+				playConcurrently(t, m, gameID, name, r)
+			}(name, rand.New(rand.NewSource(int64(i*len(players)+j))))
+		}
+
+		seen := make([]map[Phase]bool, readers)
+		for j := range seen {
+			seen[j] = make(map[Phase]bool)
+			wg.Add(1)
+			go func(seen map[Phase]bool) {
+				defer wg.Done()
+				watchConcurrently(t, m, gameID, seen)
+			}(seen[j])
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < maxAdvances; n++ {
+				snapshot, err := m.GetGame(gameID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if snapshot.Phase == PhaseGameOver {
+					return
+				}
+				time.Sleep(time.Millisecond)
+				if err := m.AdvancePhase(gameID); err != nil && ErrorCode(err) == CodeInternal {
+					t.Errorf("advancing phase: %v", err)
+				}
+			}
+		}()
+
+		t.Cleanup(func() {
+			snapshot, err := m.GetGame(gameID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if snapshot.Phase != PhaseGameOver {
+				t.Errorf("game %s still in phase %s after %d advances", gameID, snapshot.Phase, maxAdvances)
+			}
+			for _, phases := range seen {
+				for _, phase := range []Phase{PhaseNight, PhaseDiscuss, PhaseVote, PhaseGameOver} {
+					if !phases[phase] {
+						t.Errorf("game %s: phase %s never observed", gameID, phase)
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// playConcurrently acts for a player once in every phase until the player
+// dies or the game ends. Errors from acting too late are expected; only
+// errors that are not GameErrors fail the test.
+func playConcurrently(t *testing.T, m *GameManager, gameID string, playerID string, r *rand.Rand) {
+	var acted string
+	for {
+		snapshot, err := m.GetGame(gameID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		me := snapshot.Players[playerID]
+		if snapshot.Phase == PhaseGameOver || !me.IsAlive {
+			return
+		}
+		turn := fmt.Sprintf("%s/%d", snapshot.Phase, snapshot.Round)
+		if turn == acted {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		acted = turn
+
+		var targets []string
+		for _, p := range snapshot.AlivePlayers() {
+			if p.ID != playerID {
+				targets = append(targets, p.ID)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		target := targets[r.Intn(len(targets))]
+
+		switch snapshot.Phase {
+		case PhaseNight:
+			if me.IsMafia() {
+				err = m.HandleMafiaAction(gameID, playerID, target)
+			} else {
+				err = m.HandleNightAction(gameID, playerID, target)
+			}
+		case PhaseVote:
+			err = m.HandleVote(gameID, playerID, target)
+		}
+		if err != nil && ErrorCode(err) == CodeInternal {
+			t.Errorf("%s acting in %s: %v", playerID, turn, err)
+		}
+	}
+}
+
+// watchConcurrently polls snapshots until the game ends, recording the
+// phases it sees and checking that the game only ever moves forward.
+func watchConcurrently(t *testing.T, m *GameManager, gameID string, seen map[Phase]bool) {
+	round, alive := 0, -1
+	for {
+		snapshot, err := m.GetGame(gameID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		seen[snapshot.Phase] = true
+
+		living := len(snapshot.AlivePlayers())
+		if snapshot.Round < round {
+			t.Errorf("round went back from %d to %d", round, snapshot.Round)
+		}
+		if alive >= 0 && living > alive {
+			t.Errorf("players came back to life: %d alive after %d", living, alive)
+		}
+		round, alive = snapshot.Round, living
+
+		if snapshot.Phase == PhaseGameOver {
+			return
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// TestRemovePlayerOnlyFromLobby checks that players can leave a lobby but
+// not a game in progress, where night actions may target them.
+func TestRemovePlayerOnlyFromLobby(t *testing.T) {
+	m := newTestManager()
+	created, err := m.CreateGame(GameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ann", "ben", "cal", "dee", "eve"} {
+		if err := m.AddPlayer(created.ID, name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.RemovePlayer(created.ID, "eve"); err != nil {
+		t.Fatalf("removing from the lobby: %v", err)
+	}
+	if err := m.RemovePlayer(created.ID, "eve"); !errors.Is(err, ErrPlayerNotFound) {
+		t.Fatalf("removing twice: got %v, want %v", err, ErrPlayerNotFound)
+	}
+
+	if err := m.StartGame(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemovePlayer(created.ID, "ann"); !errors.Is(err, ErrGameAlreadyStarted) {
+		t.Fatalf("removing after the start: got %v, want %v", err, ErrGameAlreadyStarted)
+	}
+	snapshot, err := m.GetGame(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := snapshot.Players["ann"]; !exists {
+		t.Fatal("player was removed from a game in progress")
+	}
+}
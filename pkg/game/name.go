@@ -0,0 +1,106 @@
+package game
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Player names are counted in characters after normalization.
+const (
+	MinPlayerNameLength = 2
+	MaxPlayerNameLength = 20
+)
+
+// maxNameBytes bounds the input normalized at all, so huge names are
+// rejected before any work is done on them.
+const maxNameBytes = 256
+
+// playerNamePunctuation are the characters other than letters, digits and
+// spaces a player name may contain.
+const playerNamePunctuation = "-_.'"
+
+// reservedPlayerNames could be mistaken for the server or its staff. They
+// are compared case-insensitively.
+var reservedPlayerNames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"moderator":     true,
+	"mod":           true,
+	"system":        true,
+	"server":        true,
+	"narrator":      true,
+	"host":          true,
+	"everyone":      true,
+	"spectator":     true,
+	"nobody":        true,
+}
+
+// botName matches the names given to bots, which players may not take.
+var botName = regexp.MustCompile(`^bot \d+$`)
+
+// NormalizePlayerName returns the canonical form of a player name: NFKC
+// normalized, with runs of spaces collapsed and the ends trimmed. It fails
+// with a *FieldError if the name is too short or long, has characters other
+// than letters, digits, spaces and -_.' or is reserved.
+func NormalizePlayerName(name string) (string, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &FieldError{Field: "playerName", Reason: fmt.Sprintf(format, args...), Err: ErrInvalidPlayerName}
+	}
+
+	if len(name) > maxNameBytes {
+		return "", invalid("must be at most %d characters", MaxPlayerNameLength)
+	}
+	if !utf8.ValidString(name) {
+		return "", invalid("must be valid UTF-8")
+	}
+
+	name = norm.NFKC.String(name)
+	hasAlphanumeric := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			hasAlphanumeric = true
+		case unicode.IsMark(r) || r == ' ' || strings.ContainsRune(playerNamePunctuation, r):
+		default:
+			return "", invalid("may only contain letters, digits, spaces and %s", playerNamePunctuation)
+		}
+	}
+	name = strings.Join(strings.Fields(name), " ")
+
+	length := utf8.RuneCountInString(name)
+	if length < MinPlayerNameLength || length > MaxPlayerNameLength {
+		return "", invalid("must be between %d and %d characters", MinPlayerNameLength, MaxPlayerNameLength)
+	}
+	if !hasAlphanumeric {
+		return "", invalid("must contain a letter or digit")
+	}
+
+	key := playerNameKey(name)
+	if reservedPlayerNames[key] || botName.MatchString(key) {
+		return "", &FieldError{Field: "playerName", Reason: "is reserved", Err: ErrReservedPlayerName}
+	}
+	return name, nil
+}
+
+// playerNameKey folds the case of a normalized name, so names that differ
+// only in case compare equal.
+func playerNameKey(name string) string {
+	return cases.Fold().String(name)
+}
+
+// nameTaken reports whether a player already goes by name, ignoring case.
+func (g *Game) nameTaken(name string) bool {
+	key := playerNameKey(name)
+	for _, p := range g.Players {
+		if playerNameKey(p.Name) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestNormalizePlayerName checks the canonical form of accepted names and
+// why the others are rejected.
+func TestNormalizePlayerName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+	}{
+		{name: "plain", input: "Ann", want: "Ann"},
+		{name: "spaces collapsed and trimmed", input: "  Ann   Lee ", want: "Ann Lee"},
+		{name: "punctuation", input: "O'Neil-Jr._2", want: "O'Neil-Jr._2"},
+		{name: "fullwidth folded by NFKC", input: "Ａｎｎ", want: "Ann"},
+		{name: "combining accent composed", input: "Zoe\u0301", want: "Zo\u00e9"},
+		{name: "non-latin letters", input: "Мария", want: "Мария"},
+		{name: "longest", input: strings.Repeat("a", MaxPlayerNameLength), want: strings.Repeat("a", MaxPlayerNameLength)},
+		{name: "length counted after collapsing", input: "a" + strings.Repeat(" ", 30) + "b", want: "a b"},
+		{name: "too short", input: "a", err: ErrInvalidPlayerName},
+		{name: "only spaces", input: "     ", err: ErrInvalidPlayerName},
+		{name: "too long", input: strings.Repeat("a", MaxPlayerNameLength+1), err: ErrInvalidPlayerName},
+		{name: "huge", input: strings.Repeat("a", maxNameBytes+1), err: ErrInvalidPlayerName},
+		{name: "symbols", input: "ann!", err: ErrInvalidPlayerName},
+		{name: "markup", input: "<b>ann</b>", err: ErrInvalidPlayerName},
+		{name: "tabs", input: "ann\tlee", err: ErrInvalidPlayerName},
+		{name: "control characters", input: "ann\nlee", err: ErrInvalidPlayerName},
+		{name: "invalid UTF-8", input: "ann\xff", err: ErrInvalidPlayerName},
+		{name: "no letters or digits", input: "-_.'", err: ErrInvalidPlayerName},
+		{name: "reserved", input: "Admin", err: ErrReservedPlayerName},
+		{name: "reserved after normalizing", input: " ＳＹＳＴＥＭ ", err: ErrReservedPlayerName},
+		{name: "bot name", input: "Bot 3", err: ErrReservedPlayerName},
+		{name: "bot-like name", input: "Bot3", want: "Bot3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePlayerName(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("NormalizePlayerName(%q) = %q, %v; want %v", tt.input, got, err, tt.err)
+				}
+				var field *FieldError
+				if !errors.As(err, &field) || field.Field != "playerName" {
+					t.Fatalf("error %v does not name the playerName field", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizePlayerName(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package game
+
+import (
+	"sort"
+
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// NightAction is the kind of action a role performs with its night target.
+type NightAction string
+
+const (
+	NightActionNone        NightAction = ""
+	NightActionBlock       NightAction = "block"
+	NightActionProtect     NightAction = "protect"
+	NightActionGuard       NightAction = "guard"
+	NightActionKill        NightAction = "kill"
+	NightActionInvestigate NightAction = "investigate"
+)
+
+// Investigation is the result a detective receives about a target.
+type Investigation struct {
+	Round    int     `json:"round"`
+	TargetID string  `json:"targetId"`
+	Faction  Faction `json:"faction"`
+}
+
+// attack is a single kill attempt against a target during the night.
+type attack struct {
+	TargetID    string
+	AttackerIDs []string
+}
+
+// nightResolution accumulates the outcome of the night as stages run.
+type nightResolution struct {
+	blocked   map[string]bool
+	protected map[string]bool
+	// guards maps a guarded player to the bodyguard who will die in their place.
+	guards  map[string]string
+	attacks []attack
+}
+
+// nightStage resolves one kind of night action. Stages run in order so that
+// earlier stages can cancel or alter the effect of later ones.
+type nightStage func(g *Game, n *nightResolution)
+
+// nightPipeline is the order in which night actions resolve: roleblocks cancel
+// everything after them, protections must be known before kills land, and
+// investigations see the state of the night after the kills were chosen.
+var nightPipeline = []nightStage{
+	resolveRoleblocks,
+	resolveProtections,
+	resolveGuards,
+	resolveMafiaKill,
+	resolveKills,
+	resolveInvestigations,
+	resolveAttacks,
+}
+
+// resolveNight runs the night pipeline and resets all night choices.
+func (g *Game) resolveNight() {
+	n := &nightResolution{
+		blocked:   make(map[string]bool),
+		protected: make(map[string]bool),
+		guards:    make(map[string]string),
+	}
+	for _, stage := range nightPipeline {
+		if g.Phase == PhaseGameOver {
+			break
+		}
+		stage(g, n)
+	}
+
+	for _, player := range g.Players {
+		player.VotedFor = ""
+		player.NightTarget = ""
+	}
+}
+
+// actors returns the living players whose role performs the given night
+// action and who chose a target, in a stable order.
+func (g *Game) actors(action NightAction) []*Player {
+	actors := make([]*Player, 0)
+	for _, p := range g.Players {
+		if p.IsAlive && p.NightTarget != "" && roles[p.Role].Night == action {
+			actors = append(actors, p)
+		}
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		return actors[i].ID < actors[j].ID
+	})
+	return actors
+}
+
+func resolveRoleblocks(g *Game, n *nightResolution) {
+	for _, p := range g.actors(NightActionBlock) {
+		if n.blocked[p.ID] {
+			continue
+		}
+		n.blocked[p.NightTarget] = true
+		g.log().Info("player roleblocked", logging.KeyTarget, p.NightTarget)
+	}
+}
+
+func resolveProtections(g *Game, n *nightResolution) {
+	for _, p := range g.actors(NightActionProtect) {
+		if !n.blocked[p.ID] {
+			n.protected[p.NightTarget] = true
+		}
+	}
+}
+
+func resolveGuards(g *Game, n *nightResolution) {
+	for _, p := range g.actors(NightActionGuard) {
+		if _, guarded := n.guards[p.NightTarget]; !guarded && !n.blocked[p.ID] {
+			n.guards[p.NightTarget] = p.ID
+		}
+	}
+}
+
+// resolveMafiaKill picks the mafia target. A living godfather who voted has
+// the final say; otherwise at least half of the living mafia must agree.
+func resolveMafiaKill(g *Game, n *nightResolution) {
+	votes := make(map[string]int)
+	voters := make(map[string][]string)
+	var mafiaMembers int
+	var godfather *Player
+	for _, player := range g.Players {
+		if !player.IsAlive || !player.IsMafia() {
+			continue
+		}
+		mafiaMembers++
+		if player.VotedFor == "" || n.blocked[player.ID] {
+			continue
+		}
+		votes[player.VotedFor]++
+		voters[player.VotedFor] = append(voters[player.VotedFor], player.ID)
+		if player.Role == RoleGodfather {
+			godfather = player
+		}
+	}
+
+	// Find the target with the most votes
+	var targetID string
+	maxVotes := 0
+	for id, count := range votes {
+		if count > maxVotes || (count == maxVotes && id < targetID) {
+			maxVotes = count
+			targetID = id
+		}
+	}
+
+	if godfather != nil {
+		targetID = godfather.VotedFor
+	} else if maxVotes < (mafiaMembers+1)/2 {
+		return
+	}
+
+	if targetID == "" || roles[g.Players[targetID].Role].Immune {
+		return
+	}
+	sort.Strings(voters[targetID])
+	n.attacks = append(n.attacks, attack{TargetID: targetID, AttackerIDs: voters[targetID]})
+}
+
+// resolveKills queues the independent night kills of serial killers and
+// vigilantes. Vigilantes spend a shot even if their target survives.
+func resolveKills(g *Game, n *nightResolution) {
+	for _, p := range g.actors(NightActionKill) {
+		if n.blocked[p.ID] {
+			continue
+		}
+		if p.Role == RoleVigilante {
+			if p.Shots <= 0 {
+				continue
+			}
+			p.Shots--
+		}
+		n.attacks = append(n.attacks, attack{TargetID: p.NightTarget, AttackerIDs: []string{p.ID}})
+	}
+}
+
+// resolveInvestigations records what each detective learned. Roles with an
+// apparent faction, such as the godfather, show that instead of their own.
+func resolveInvestigations(g *Game, n *nightResolution) {
+	for _, p := range g.actors(NightActionInvestigate) {
+		if n.blocked[p.ID] {
+			continue
+		}
+		target := g.Players[p.NightTarget]
+		faction := FactionOf(target.Role)
+		if appears := roles[target.Role].AppearsAs; appears != "" {
+			faction = appears
+		}
+		p.Investigations = append(p.Investigations, Investigation{
+			Round:    g.Round,
+			TargetID: target.ID,
+			Faction:  faction,
+		})
+	}
+}
+
+// resolveAttacks applies the queued attacks. A bodyguard dies in place of the
+// player they guard and takes the first attacker down with them; medics save
+// whoever they protected, including bodyguards and attackers. All deaths are
+// judged together once the night is over.
+func resolveAttacks(g *Game, n *nightResolution) {
+	var deaths, suicides []string
+	kill := func(id string) {
+		if n.protected[id] {
+			g.log().Info("attack prevented", logging.KeyTarget, id)
+			return
+		}
+		g.log().Info("player killed", logging.KeyPlayerID, id)
+		deaths = append(deaths, id)
+	}
+
+	for _, a := range n.attacks {
+		if bodyguardID, guarded := n.guards[a.TargetID]; guarded {
+			delete(n.guards, a.TargetID)
+			kill(bodyguardID)
+			if len(a.AttackerIDs) > 0 {
+				kill(a.AttackerIDs[0])
+			}
+			continue
+		}
+
+		before := len(deaths)
+		kill(a.TargetID)
+		if len(deaths) == before || len(a.AttackerIDs) == 0 {
+			continue
+		}
+
+		// A vigilante who kills a member of the town may die of guilt
+		attacker := g.Players[a.AttackerIDs[0]]
+		if attacker.Role == RoleVigilante && g.VigilanteSuicide && FactionOf(g.Players[a.TargetID].Role) == FactionTown {
+			suicides = append(suicides, attacker.ID)
+		}
+	}
+
+	if len(deaths)+len(suicides) == 0 {
+		return
+	}
+	for _, id := range deaths {
+		g.markDead(id, DeathNightKill)
+	}
+	for _, id := range suicides {
+		g.markDead(id, DeathSuicide)
+	}
+	g.evaluateWin()
+}
@@ -0,0 +1,194 @@
+package game
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestResolveNight runs the night pipeline and checks who died, so each
+// stage is seen cancelling or altering the ones after it.
+func TestResolveNight(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles map[string]Role
+		// votes are the mafia kill votes, targets the night actions.
+		votes   map[string]string
+		targets map[string]string
+		suicide bool
+		dead    []string
+	}{
+		{
+			name:  "mafia kill lands",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleVillager, "dee": RoleVillager},
+			votes: map[string]string{"ann": "cal"},
+			dead:  []string{"cal"},
+		},
+		{
+			name:  "split mafia vote kills no one",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleMafia, "cal": RoleMafia, "dee": RoleVillager, "eve": RoleVillager, "fay": RoleVillager, "gus": RoleVillager},
+			votes: map[string]string{"ann": "dee", "ben": "eve"},
+		},
+		{
+			name:  "godfather has the final say",
+			roles: map[string]Role{"ann": RoleGodfather, "ben": RoleMafia, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleVillager},
+			votes: map[string]string{"ann": "cal", "ben": "dee"},
+			dead:  []string{"cal"},
+		},
+		{
+			name:    "medic saves the target",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleVillager, "dee": RoleMedic},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"dee": "cal"},
+		},
+		{
+			name:    "roleblock cancels the save",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleRoleblocker, "cal": RoleVillager, "dee": RoleMedic, "eve": RoleVillager, "fay": RoleVillager},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"ben": "dee", "dee": "cal"},
+			dead:    []string{"cal"},
+		},
+		{
+			name:    "roleblocked mafia cannot vote",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleRoleblocker},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"eve": "ann"},
+		},
+		{
+			name:    "bodyguard dies in place and takes the attacker",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleVillager, "dee": RoleBodyguard, "eve": RoleVillager},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"dee": "cal"},
+			dead:    []string{"ann", "dee"},
+		},
+		{
+			name:    "medic saves the bodyguard",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleMedic, "cal": RoleVillager, "dee": RoleBodyguard, "eve": RoleVillager},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"ben": "dee", "dee": "cal"},
+			dead:    []string{"ann"},
+		},
+		{
+			name:  "serial killer is immune to the mafia kill",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleSerialKiller, "cal": RoleVillager, "dee": RoleVillager},
+			votes: map[string]string{"ann": "ben"},
+		},
+		{
+			name:    "mafia and serial killer both kill",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleSerialKiller, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleVillager, "fay": RoleVillager},
+			votes:   map[string]string{"ann": "cal"},
+			targets: map[string]string{"ben": "dee"},
+			dead:    []string{"cal", "dee"},
+		},
+		{
+			name:    "vigilante dies of guilt after killing town",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVigilante, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleVillager},
+			targets: map[string]string{"ben": "cal"},
+			suicide: true,
+			dead:    []string{"ben", "cal"},
+		},
+		{
+			name:    "vigilante survives killing town without guilt",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVigilante, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleVillager},
+			targets: map[string]string{"ben": "cal"},
+			dead:    []string{"cal"},
+		},
+		{
+			name:    "vigilante feels no guilt over the mafia",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleVigilante, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleMafia},
+			targets: map[string]string{"ben": "ann"},
+			suicide: true,
+			dead:    []string{"ann"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(tt.roles)
+			g.VigilanteSuicide = tt.suicide
+			for _, p := range g.Players {
+				if p.Role == RoleVigilante {
+					p.Shots = g.VigilanteShots
+				}
+			}
+			for id, target := range tt.votes {
+				g.Players[id].VotedFor = target
+			}
+			for id, target := range tt.targets {
+				g.Players[id].NightTarget = target
+			}
+
+			g.resolveNight()
+
+			var dead []string
+			for _, death := range g.Deaths {
+				dead = append(dead, death.PlayerID)
+			}
+			sort.Strings(dead)
+			if !reflect.DeepEqual(dead, tt.dead) {
+				t.Errorf("dead %v, want %v", dead, tt.dead)
+			}
+			for id, p := range g.Players {
+				if p.VotedFor != "" || p.NightTarget != "" {
+					t.Errorf("%s still has a night choice after the night", id)
+				}
+			}
+		})
+	}
+}
+
+// TestNightInvestigations checks what a detective learns from the night.
+func TestNightInvestigations(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   map[string]Role
+		targets map[string]string
+		want    []Faction
+	}{
+		{
+			name:    "mafia shows as mafia",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleDetective, "cal": RoleVillager},
+			targets: map[string]string{"ben": "ann"},
+			want:    []Faction{FactionMafia},
+		},
+		{
+			name:    "godfather appears as town",
+			roles:   map[string]Role{"ann": RoleGodfather, "ben": RoleDetective, "cal": RoleVillager},
+			targets: map[string]string{"ben": "ann"},
+			want:    []Faction{FactionTown},
+		},
+		{
+			name:    "serial killer shows as neutral",
+			roles:   map[string]Role{"ann": RoleMafia, "ben": RoleDetective, "cal": RoleSerialKiller},
+			targets: map[string]string{"ben": "cal"},
+			want:    []Faction{FactionNeutral},
+		},
+		{
+			name:    "roleblocked detective learns nothing",
+			roles:   map[string]Role{"ann": RoleRoleblocker, "ben": RoleDetective, "cal": RoleVillager},
+			targets: map[string]string{"ann": "ben", "ben": "ann"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(tt.roles)
+			for id, target := range tt.targets {
+				g.Players[id].NightTarget = target
+			}
+
+			g.resolveNight()
+
+			var got []Faction
+			for _, inv := range g.Players["ben"].Investigations {
+				got = append(got, inv.Faction)
+				if inv.Round != g.Round || inv.TargetID != tt.targets["ben"] {
+					t.Errorf("investigation %+v, want round %d target %s", inv, g.Round, tt.targets["ben"])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("learned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Lobby passwords are limited to what bcrypt can hash.
+const MaxPasswordLength = 72
+
+// A client that fails MaxPasswordAttempts times within PasswordAttemptWindow
+// is locked out of that game for PasswordLockout.
+const (
+	MaxPasswordAttempts   = 5
+	PasswordAttemptWindow = 5 * time.Minute
+	PasswordLockout       = 5 * time.Minute
+)
+
+func hashPassword(password string) ([]byte, error) {
+	if len(password) > MaxPasswordLength {
+		return nil, ErrPasswordTooLong
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+type attemptKey struct {
+	gameID string
+	ip     string
+}
+
+type attempts struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// passwordThrottle limits password guesses per IP per game.
+type passwordThrottle struct {
+	attempts map[attemptKey]*attempts
+	mu       sync.Mutex
+}
+
+func newPasswordThrottle() *passwordThrottle {
+	return &passwordThrottle{
+		attempts: make(map[attemptKey]*attempts),
+	}
+}
+
+func (t *passwordThrottle) allow(key attemptKey, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, exists := t.attempts[key]
+	return !exists || !now.Before(a.lockedUntil)
+}
+
+func (t *passwordThrottle) fail(key attemptKey, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, exists := t.attempts[key]
+	if !exists || now.Sub(a.windowStart) > PasswordAttemptWindow {
+		a = &attempts{windowStart: now}
+		t.attempts[key] = a
+	}
+	a.failures++
+	if a.failures >= MaxPasswordAttempts {
+		a.lockedUntil = now.Add(PasswordLockout)
+		a.failures = 0
+	}
+}
+
+func (t *passwordThrottle) succeed(key attemptKey) {
+	t.mu.Lock()
+	delete(t.attempts, key)
+	t.mu.Unlock()
+}
+
+// forget drops all attempts recorded against a game.
+func (t *passwordThrottle) forget(gameID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.attempts {
+		if key.gameID == gameID {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// CheckPassword verifies a lobby password on behalf of the client at ip.
+// Games without a password accept any input. Repeated failures from the same
+// IP lock it out of the game for a while.
+func (m *GameManager) CheckPassword(gameID string, ip string, password string) error {
+	game, err := m.game(gameID)
+	if err != nil {
+		return err
+	}
+
+	if len(game.passwordHash) == 0 {
+		return nil
+	}
+
+	key := attemptKey{gameID: gameID, ip: ip}
+	now := m.clock.Now()
+	if !m.throttle.allow(key, now) {
+		return ErrTooManyAttempts
+	}
+
+	if err := bcrypt.CompareHashAndPassword(game.passwordHash, []byte(password)); err != nil {
+		m.throttle.fail(key, now)
+		return ErrInvalidPassword
+	}
+
+	m.throttle.succeed(key)
+	return nil
+}
@@ -0,0 +1,355 @@
+package game
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// Phase durations
+const (
+	NightDuration   = 30 * time.Second
+	DiscussDuration = 120 * time.Second
+	VoteDuration    = 30 * time.Second
+)
+
+// The methods in this file implement the game rules. They only ever run on
+// the game's own goroutine, as commands sent by GameManager, and must never
+// call back into GameManager.
+
+func (g *Game) start(seed int64, source rand.Source) error {
+	if g.Phase != PhaseWaiting {
+		return ErrGameAlreadyStarted
+	}
+
+	if len(g.Players) < g.MinPlayers {
+		return ErrNotEnoughPlayers
+	}
+
+	// Assign roles
+	players := make([]*Player, 0, len(g.Players))
+	for _, p := range g.Players {
+		players = append(players, p)
+	}
+
+	g.Phase = PhaseNight
+	g.Round = 1
+	g.PhaseEndTime = g.clock.Now().Add(NightDuration)
+
+	// Shuffle players with a recorded seed so the assignment can be replayed
+	g.Seed = seed
+	shufflePlayers(players, rand.New(source))
+
+	// Deal roles from the deck built from the game settings
+	deck := g.GameSettings.roleDeck(len(players))
+	for i, p := range players {
+		p.Role = deck[i]
+		if p.Role == RoleVigilante {
+			p.Shots = g.VigilanteShots
+		}
+		g.log().Debug("role assigned", logging.KeyPlayerID, p.ID, logging.KeyRole, p.Role)
+	}
+
+	return nil
+}
+
+// startAsHost starts the game on behalf of a player, who must be the host.
+func (g *Game) startAsHost(playerID string, seed int64, source rand.Source) error {
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if !player.IsHost {
+		return ErrNotHost
+	}
+	return g.start(seed, source)
+}
+
+func (g *Game) advancePhase() error {
+	switch g.Phase {
+	case PhaseNight:
+		// Process night actions before moving to discussion
+		g.resolveNight()
+		if g.Phase == PhaseGameOver {
+			return nil
+		}
+		g.Phase = PhaseDiscuss
+		g.PhaseEndTime = g.clock.Now().Add(DiscussDuration)
+		g.log().Info("discussion started")
+
+	case PhaseDiscuss:
+		g.Phase = PhaseVote
+		g.PhaseEndTime = g.clock.Now().Add(VoteDuration)
+		g.log().Info("voting started")
+
+	case PhaseVote:
+		// Process votes and eliminate player
+		if eliminated := g.tallyVotes(); eliminated != "" {
+			g.log().Info("player eliminated", logging.KeyPlayerID, eliminated)
+		}
+
+		// Win conditions are evaluated as each player dies
+		if g.Phase == PhaseGameOver {
+			return nil
+		}
+
+		// If game isn't over, start next night phase
+		g.Phase = PhaseNight
+		g.Round++
+		g.PhaseEndTime = g.clock.Now().Add(NightDuration)
+		g.log().Info("night started")
+
+	default:
+		return ErrInvalidPhase
+	}
+
+	return nil
+}
+
+func (g *Game) vote(voterID, targetID string) error {
+	if g.Phase != PhaseVote {
+		return ErrInvalidPhase
+	}
+
+	voter, exists := g.Players[voterID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !voter.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	target, exists := g.Players[targetID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !target.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	voter.VotedFor = targetID
+	return nil
+}
+
+// tallyVotes eliminates the player with the most day votes and returns
+// their ID, or "" if nobody received a vote.
+func (g *Game) tallyVotes() string {
+	votes := make(map[string]int)
+	for _, player := range g.Players {
+		if player.IsAlive && player.VotedFor != "" {
+			votes[player.VotedFor] += player.voteWeight()
+		}
+	}
+
+	// Ties go to the lowest player ID so that seeded games replay exactly
+	maxVotes := 0
+	var eliminated string
+	for playerID, voteCount := range votes {
+		if voteCount > maxVotes || (voteCount == maxVotes && playerID < eliminated) {
+			maxVotes = voteCount
+			eliminated = playerID
+		}
+	}
+
+	// Reset votes
+	for _, player := range g.Players {
+		player.VotedFor = ""
+	}
+
+	if eliminated != "" {
+		g.killPlayers(DeathLynch, eliminated)
+	}
+
+	return eliminated
+}
+
+func (g *Game) mafiaAction(mafiaID, targetID string) error {
+	if g.Phase != PhaseNight {
+		return ErrInvalidPhase
+	}
+
+	mafia, exists := g.Players[mafiaID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !mafia.IsMafia() {
+		return ErrNotMafia
+	}
+
+	target, exists := g.Players[targetID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !target.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	// Record the mafia's vote
+	mafia.VotedFor = targetID
+	g.log().Info("mafia voted", logging.KeyActor, mafia.ID, logging.KeyTarget, target.ID)
+
+	return nil
+}
+
+func (g *Game) nightAction(playerID, targetID string) error {
+	if g.Phase != PhaseNight {
+		return ErrInvalidPhase
+	}
+
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !player.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	if roles[player.Role].Night == NightActionNone {
+		return ErrNoNightAction
+	}
+
+	if player.Role == RoleVigilante && player.Shots <= 0 {
+		return ErrNoShotsLeft
+	}
+
+	target, exists := g.Players[targetID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !target.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	if targetID == playerID {
+		return ErrInvalidTarget
+	}
+
+	player.NightTarget = targetID
+	g.log().Info("night action chosen", logging.KeyActor, player.ID, logging.KeyRole, player.Role, logging.KeyTarget, target.ID)
+
+	return nil
+}
+
+func (g *Game) revealMayor(playerID string) error {
+	if g.Phase != PhaseDiscuss && g.Phase != PhaseVote {
+		return ErrInvalidPhase
+	}
+
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	if !player.IsAlive {
+		return ErrPlayerNotAlive
+	}
+
+	if player.Role != RoleMayor || player.Revealed {
+		return ErrCannotReveal
+	}
+
+	player.Revealed = true
+	g.log().Info("mayor revealed", logging.KeyPlayerID, player.ID)
+
+	return nil
+}
+
+func (g *Game) forfeit(playerID string) error {
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+
+	switch g.Phase {
+	case PhaseWaiting:
+		delete(g.Players, playerID)
+	case PhaseGameOver:
+		return ErrInvalidPhase
+	default:
+		if !player.IsAlive {
+			return ErrPlayerNotAlive
+		}
+		g.log().Info("player forfeited", logging.KeyPlayerID, player.ID)
+		g.killPlayers(DeathForfeit, playerID)
+	}
+
+	return nil
+}
+
+func (g *Game) updateSettings(settings GameSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	if g.Phase != PhaseWaiting {
+		return ErrGameAlreadyStarted
+	}
+
+	if len(g.Players) > settings.MaxPlayers {
+		return ErrInvalidSettings
+	}
+
+	g.GameSettings = settings
+	return nil
+}
+
+// updateSettingsAsHost changes the settings on behalf of a player, who must
+// be the host.
+func (g *Game) updateSettingsAsHost(playerID string, settings GameSettings) error {
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if !player.IsHost {
+		return ErrNotHost
+	}
+	return g.updateSettings(settings)
+}
+
+// rematch archives a finished game and returns it to the lobby with the same
+// players and settings. Only the host can ask for a rematch.
+func (g *Game) rematch(playerID string, archive Archive) error {
+	player, exists := g.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if !player.IsHost {
+		return ErrNotHost
+	}
+	if g.Phase != PhaseGameOver {
+		return ErrInvalidPhase
+	}
+
+	if archive != nil {
+		if err := archive.ArchiveGame(g.Snapshot()); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range g.Players {
+		p.Role = ""
+		p.IsAlive = true
+		p.VotedFor = ""
+		p.NightTarget = ""
+		p.Revealed = false
+		p.Investigations = nil
+		p.Shots = 0
+	}
+	g.Phase = PhaseWaiting
+	g.Round = 0
+	g.PhaseEndTime = time.Time{}
+	g.Deaths = nil
+	g.Result = nil
+	g.Seed = 0
+	g.soloWinners = nil
+	g.log().Info("rematch started", logging.KeyPlayerID, player.ID)
+
+	return nil
+}
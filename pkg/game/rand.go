@@ -0,0 +1,41 @@
+package game
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+)
+
+// Seeder produces the seed recorded on a game when its roles are assigned.
+type Seeder func() int64
+
+// SourceFactory builds the random source used to shuffle roles from a game seed.
+type SourceFactory func(seed int64) rand.Source
+
+// CryptoSeeder returns a seed read from the operating system's CSPRNG.
+func CryptoSeeder() int64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic("game: unable to read random seed: " + err.Error())
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// DefaultSourceFactory seeds a math/rand source, so that a recorded seed
+// reproduces the exact same shuffle.
+func DefaultSourceFactory(seed int64) rand.Source {
+	return rand.NewSource(seed)
+}
+
+// shufflePlayers performs an unbiased Fisher–Yates shuffle. Players are first
+// sorted by ID so the outcome depends only on the seed, not on map iteration.
+func shufflePlayers(players []*Player, r *rand.Rand) {
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+	for i := len(players) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		players[i], players[j] = players[j], players[i]
+	}
+}
@@ -0,0 +1,90 @@
+package game
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/silent-vendetta/pkg/clock"
+)
+
+// chiSquareCritical holds the chi-square values that are exceeded with a
+// probability of 0.001, by degrees of freedom.
+var chiSquareCritical = map[int]float64{
+	1: 10.828,
+	2: 13.816,
+	3: 16.266,
+	4: 18.467,
+	5: 20.515,
+}
+
+// newTestManager returns a manager on a fake clock that logs nowhere.
+func newTestManager(opts ...Option) *GameManager {
+	defaults := []Option{
+		WithClock(clock.NewFake(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC))),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}
+	return NewGameManager(append(defaults, opts...)...)
+}
+
+// TestRoleDistributionIsUniform starts many seeded games and checks that
+// every seat is dealt each role as often as the deck makes it likely.
+func TestRoleDistributionIsUniform(t *testing.T) {
+	const starts = 3000
+	seats := []string{"ann", "ben", "cal", "dee", "eve", "fay", "gus"}
+
+	var seed int64
+	m := newTestManager(WithSeeder(func() int64 {
+		seed++
+		return seed
+	}))
+
+	counts := make(map[string]map[Role]int, len(seats))
+	for _, name := range seats {
+		counts[name] = make(map[Role]int)
+	}
+	for i := 0; i < starts; i++ {
+		created, err := m.CreateGame(GameOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range seats {
+			if err := m.AddPlayer(created.ID, name, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := m.StartGame(created.ID); err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := m.GetGame(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, p := range snapshot.Players {
+			counts[id][p.Role]++
+		}
+		m.RemoveGame(created.ID)
+	}
+
+	deck := make(map[Role]int)
+	for _, role := range DefaultSettings().roleDeck(len(seats)) {
+		deck[role]++
+	}
+	critical, ok := chiSquareCritical[len(deck)-1]
+	if !ok {
+		t.Fatalf("no critical value for %d degrees of freedom", len(deck)-1)
+	}
+
+	for _, name := range seats {
+		var chiSquare float64
+		for role, n := range deck {
+			expected := float64(starts*n) / float64(len(seats))
+			diff := float64(counts[name][role]) - expected
+			chiSquare += diff * diff / expected
+		}
+		if chiSquare > critical {
+			t.Errorf("seat %s: chi-square %.2f exceeds %.2f, roles dealt %v", name, chiSquare, critical, counts[name])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"log/slog"
+
+	"github.com/silent-vendetta/pkg/clock"
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// PlayerSecrets holds the parts of a player's state that are never sent to
+// clients.
+type PlayerSecrets struct {
+	NightTarget    string          `json:"nightTarget,omitempty"`
+	Investigations []Investigation `json:"investigations,omitempty"`
+	Shots          int             `json:"shots,omitempty"`
+	Token          string          `json:"token,omitempty"`
+}
+
+// Record is the complete state of a game, including what snapshots keep
+// from clients, so that it can be saved and restored after a restart.
+type Record struct {
+	State
+	Seed         int64                    `json:"seed"`
+	PasswordHash []byte                   `json:"passwordHash,omitempty"`
+	SoloWinners  []string                 `json:"soloWinners,omitempty"`
+	Secrets      map[string]PlayerSecrets `json:"secrets,omitempty"`
+}
+
+// Records returns the latest state of every game.
+func (m *GameManager) Records() []Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]Record, 0, len(m.games))
+	for _, game := range m.games {
+		records = append(records, game.record())
+	}
+	return records
+}
+
+// Record returns the latest state of a game, secrets included.
+func (m *GameManager) Record(id string) (Record, error) {
+	game, err := m.game(id)
+	if err != nil {
+		return Record{}, err
+	}
+	return game.record(), nil
+}
+
+// record builds a record from the game's latest snapshot.
+func (g *Game) record() Record {
+	snapshot := g.Snapshot()
+	record := Record{
+		State:        snapshot.State,
+		Seed:         snapshot.seed,
+		PasswordHash: g.passwordHash,
+		SoloWinners:  snapshot.soloWinners,
+		Secrets:      make(map[string]PlayerSecrets, len(snapshot.Players)),
+	}
+	for id, p := range snapshot.Players {
+		record.Secrets[id] = PlayerSecrets{
+			NightTarget:    p.NightTarget,
+			Investigations: p.Investigations,
+			Shots:          p.Shots,
+			Token:          p.Token,
+		}
+	}
+	return record
+}
+
+// Restore brings saved games back to life. Games whose ID is already in use
+// are skipped, and a game whose join code was taken gets a new one. Phase
+// timers that expired while the games were saved fire right away.
+func (m *GameManager) Restore(records []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, record := range records {
+		if _, exists := m.games[record.ID]; exists {
+			continue
+		}
+		if _, taken := m.codes[record.Code]; taken || record.Code == "" {
+			code, err := m.allocateCode(record.ID)
+			if err != nil {
+				return err
+			}
+			record.Code = code
+		} else {
+			m.codes[record.Code] = record.ID
+		}
+		m.games[record.ID] = restoreGame(record, m.clock, m.logger, m.publish)
+	}
+	return nil
+}
+
+// restoreGame recreates a game from a record and starts its goroutine.
+func restoreGame(record Record, clock clock.Clock, logger *slog.Logger, notify func(Event)) *Game {
+	g := &Game{
+		State:        record.State.clone(),
+		Seed:         record.Seed,
+		soloWinners:  append([]string(nil), record.SoloWinners...),
+		passwordHash: record.PasswordHash,
+		cmds:         make(chan command),
+		done:         make(chan struct{}),
+		clock:        clock,
+		logger:       logger.With(logging.KeyGameID, record.ID),
+		notify:       notify,
+	}
+	for id, secrets := range record.Secrets {
+		if p, exists := g.Players[id]; exists {
+			p.NightTarget = secrets.NightTarget
+			p.Investigations = append([]Investigation(nil), secrets.Investigations...)
+			p.Shots = secrets.Shots
+			p.Token = secrets.Token
+		}
+	}
+	g.publish()
+	go g.run()
+	return g
+}
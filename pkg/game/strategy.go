@@ -0,0 +1,176 @@
+package game
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Names of the built-in bot strategies.
+const (
+	BotStrategyRandom    = "random"
+	BotStrategySuspicion = "suspicion"
+)
+
+// BotStrategyByName returns a built-in strategy. An empty name selects the
+// suspicion strategy.
+func BotStrategyByName(name string) (BotStrategy, error) {
+	switch name {
+	case BotStrategyRandom:
+		return RandomStrategy{}, nil
+	case "", BotStrategySuspicion:
+		return SuspicionStrategy{}, nil
+	}
+	return nil, ErrUnknownBotStrategy
+}
+
+// RandomStrategy picks every target uniformly at random, sparing only the
+// bot itself and, for mafia, its partners.
+type RandomStrategy struct{}
+
+func (RandomStrategy) NightTarget(view *Snapshot, self *Player, r *rand.Rand) string {
+	return pickRandom(candidates(view, self), r)
+}
+
+func (RandomStrategy) DayVote(view *Snapshot, self *Player, r *rand.Rand) string {
+	return pickRandom(candidates(view, self), r)
+}
+
+// SuspicionStrategy votes for whoever looks most guilty from what the bot
+// knows: investigation results, revealed mayors, who is voting against it
+// and where the votes are already going. At night it protects and attacks
+// the players that matter most.
+type SuspicionStrategy struct{}
+
+func (s SuspicionStrategy) NightTarget(view *Snapshot, self *Player, r *rand.Rand) string {
+	others := candidates(view, self)
+
+	switch {
+	case self.IsMafia():
+		// A revealed mayor is the biggest threat to the mafia
+		if mayor := revealedMayor(others); mayor != "" {
+			return mayor
+		}
+		return pickRandom(others, r)
+
+	case self.Role == RoleMedic || self.Role == RoleBodyguard:
+		if mayor := revealedMayor(others); mayor != "" {
+			return mayor
+		}
+		return pickRandom(others, r)
+
+	case self.Role == RoleDetective:
+		investigated := make(map[string]bool)
+		for _, inv := range self.Investigations {
+			investigated[inv.TargetID] = true
+		}
+		unknown := make([]*Player, 0, len(others))
+		for _, p := range others {
+			if !investigated[p.ID] {
+				unknown = append(unknown, p)
+			}
+		}
+		return pickRandom(unknown, r)
+
+	case self.Role == RoleVigilante:
+		// Hold fire on the first night, when there is nothing to go on
+		if view.Round < 2 || r.Intn(2) == 0 {
+			return ""
+		}
+		return mostSuspicious(s.suspicion(view, self), r)
+	}
+
+	return pickRandom(others, r)
+}
+
+func (s SuspicionStrategy) DayVote(view *Snapshot, self *Player, r *rand.Rand) string {
+	return mostSuspicious(s.suspicion(view, self), r)
+}
+
+// suspicion scores every player the bot could vote against.
+func (SuspicionStrategy) suspicion(view *Snapshot, self *Player) map[string]int {
+	scores := make(map[string]int)
+	for _, p := range candidates(view, self) {
+		scores[p.ID] = 0
+		if p.VotedFor == self.ID {
+			scores[p.ID] += 2
+		}
+		if p.Revealed && !self.IsMafia() {
+			scores[p.ID] -= 10
+		}
+	}
+
+	// Follow the votes already cast
+	for _, p := range view.Players {
+		if _, ok := scores[p.VotedFor]; ok && p.IsAlive {
+			scores[p.VotedFor]++
+		}
+	}
+
+	for _, inv := range self.Investigations {
+		if _, ok := scores[inv.TargetID]; !ok {
+			continue
+		}
+		if inv.Faction == FactionMafia {
+			scores[inv.TargetID] += 10
+		} else {
+			scores[inv.TargetID] -= 10
+		}
+	}
+
+	return scores
+}
+
+// candidates returns the living players the bot may target, in ID order.
+// Mafia bots never target their partners.
+func candidates(view *Snapshot, self *Player) []*Player {
+	players := make([]*Player, 0, len(view.Players))
+	for _, p := range view.Players {
+		if !p.IsAlive || p.ID == self.ID || self.IsMafia() && p.IsMafia() {
+			continue
+		}
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+	return players
+}
+
+func pickRandom(players []*Player, r *rand.Rand) string {
+	if len(players) == 0 {
+		return ""
+	}
+	return players[r.Intn(len(players))].ID
+}
+
+func revealedMayor(players []*Player) string {
+	for _, p := range players {
+		if p.Revealed {
+			return p.ID
+		}
+	}
+	return ""
+}
+
+// mostSuspicious returns the highest scoring player, breaking ties at random.
+func mostSuspicious(scores map[string]int, r *rand.Rand) string {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var top []string
+	for _, id := range ids {
+		switch {
+		case len(top) == 0 || scores[id] > scores[top[0]]:
+			top = []string{id}
+		case scores[id] == scores[top[0]]:
+			top = append(top, id)
+		}
+	}
+	if len(top) == 0 {
+		return ""
+	}
+	return top[r.Intn(len(top))]
+}
@@ -0,0 +1,34 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// tokenBytes is the number of random bytes in a player token.
+const tokenBytes = 16
+
+func newToken() string {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		panic("game: unable to generate player token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Authenticate checks that token is the one the player was given when they
+// were seated. Unknown players and wrong tokens are both reported as
+// ErrInvalidToken, so it cannot be used to probe who is seated.
+func (m *GameManager) Authenticate(gameID string, playerID string, token string) error {
+	game, err := m.game(gameID)
+	if err != nil {
+		return err
+	}
+
+	player, exists := game.Snapshot().Players[playerID]
+	if !exists || player.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(player.Token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestAuthenticate checks that only the token a player was seated with
+// speaks for them, and that it survives saving and restoring the game.
+func TestAuthenticate(t *testing.T) {
+	m := newTestManager()
+	created, err := m.CreateGame(GameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := m.SeatPlayer(created.ID, "ann", "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ben, err := m.SeatPlayer(created.ID, "ben", "ben")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann == "" || ann == ben {
+		t.Fatalf("tokens %q and %q are not distinct secrets", ann, ben)
+	}
+
+	tests := []struct {
+		name     string
+		playerID string
+		token    string
+		want     error
+	}{
+		{"own token", "ann", ann, nil},
+		{"no token", "ann", "", ErrInvalidToken},
+		{"other player's token", "ann", ben, ErrInvalidToken},
+		{"unknown player", "cal", ann, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Authenticate(created.ID, tt.playerID, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	restored := newTestManager()
+	if err := restored.Restore(m.Records()); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Authenticate(created.ID, "ben", ben); err != nil {
+		t.Fatalf("token lost when the game was restored: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package game
+
+// SpectatorView returns a copy of the snapshot that is safe to show to
+// spectators of a live game: roles and night choices stay hidden until the
+// game is over.
+func (s *Snapshot) SpectatorView() *Snapshot {
+	if s.Phase == PhaseGameOver {
+		return s
+	}
+	return s.redacted(func(p *Player) bool { return false })
+}
+
+// PlayerView returns a copy of the snapshot as a player is entitled to see
+// it: their own role and investigations and the identity of fellow mafia,
+// but no other secrets until the game is over.
+func (s *Snapshot) PlayerView(playerID string) *Snapshot {
+	if s.Phase == PhaseGameOver {
+		return s
+	}
+
+	viewer, exists := s.Players[playerID]
+	return s.redacted(func(p *Player) bool {
+		return exists && (p.ID == viewer.ID || viewer.IsMafia() && p.IsMafia())
+	})
+}
+
+// redacted copies the snapshot, hiding the secrets of every player the
+// viewer does not know.
+func (s *Snapshot) redacted(known func(p *Player) bool) *Snapshot {
+	view := &Snapshot{State: s.State}
+	view.Players = make(map[string]*Player, len(s.Players))
+	for id, p := range s.Players {
+		player := *p
+		if !known(p) {
+			player.Role = ""
+			player.Investigations = nil
+			player.Shots = 0
+			if s.Phase == PhaseNight {
+				// Mafia record their kill votes in VotedFor at night
+				player.VotedFor = ""
+			}
+		}
+		view.Players[id] = &player
+	}
+	return view
+}
@@ -0,0 +1,238 @@
+package game
+
+import (
+	"sort"
+	"time"
+
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+type Faction string
+
+const (
+	FactionTown    Faction = "town"
+	FactionMafia   Faction = "mafia"
+	FactionNeutral Faction = "neutral"
+)
+
+// Objective describes how a neutral role wins independently of the
+// town/mafia outcome. Town and mafia roles use ObjectiveFaction.
+type Objective int
+
+const (
+	// ObjectiveFaction wins together with the rest of the role's faction.
+	ObjectiveFaction Objective = iota
+	// ObjectiveLynched wins the moment the player is eliminated by day vote.
+	// The game ends right there and the player wins alone.
+	ObjectiveLynched
+	// ObjectiveLastStanding wins alone once no one else can oppose the player.
+	ObjectiveLastStanding
+)
+
+// roleInfo holds the traits of a role: how it wins, what it does at night
+// and how it interacts with other roles' actions.
+type roleInfo struct {
+	Faction   Faction
+	Objective Objective
+	Night     NightAction
+	// AppearsAs overrides the faction reported to investigations.
+	AppearsAs Faction
+	// Immune roles survive the mafia kill.
+	Immune bool
+}
+
+var roles = map[Role]roleInfo{
+	RoleMafia:       {Faction: FactionMafia},
+	RoleGodfather:   {Faction: FactionMafia, AppearsAs: FactionTown},
+	RoleRoleblocker: {Faction: FactionMafia, Night: NightActionBlock},
+	RoleVillager:    {Faction: FactionTown},
+	RoleDetective:   {Faction: FactionTown, Night: NightActionInvestigate},
+	RoleMedic:       {Faction: FactionTown, Night: NightActionProtect},
+	RoleVigilante:   {Faction: FactionTown, Night: NightActionKill},
+	RoleBodyguard:   {Faction: FactionTown, Night: NightActionGuard},
+	RoleMayor:       {Faction: FactionTown},
+
+	RoleJester:       {Faction: FactionNeutral, Objective: ObjectiveLynched},
+	RoleSerialKiller: {Faction: FactionNeutral, Objective: ObjectiveLastStanding, Night: NightActionKill, Immune: true},
+}
+
+// FactionOf returns the faction a role belongs to. Unknown roles are town.
+func FactionOf(role Role) Faction {
+	if info, ok := roles[role]; ok {
+		return info.Faction
+	}
+	return FactionTown
+}
+
+// IsMafia reports whether the player belongs to the mafia faction.
+func (p *Player) IsMafia() bool {
+	return FactionOf(p.Role) == FactionMafia
+}
+
+func objectiveOf(role Role) Objective {
+	return roles[role].Objective
+}
+
+type DeathCause string
+
+const (
+	DeathNightKill DeathCause = "nightKill"
+	DeathLynch     DeathCause = "lynch"
+	DeathForfeit   DeathCause = "forfeit"
+	DeathSuicide   DeathCause = "suicide"
+)
+
+// Death records how and when a player left the game.
+type Death struct {
+	PlayerID string     `json:"playerId"`
+	Cause    DeathCause `json:"cause"`
+	Round    int        `json:"round"`
+}
+
+// Winner is a single player credited with a win.
+type Winner struct {
+	PlayerID string  `json:"playerId"`
+	Name     string  `json:"name"`
+	Role     Role    `json:"role"`
+	Faction  Faction `json:"faction"`
+}
+
+// GameResult is recorded on a game once it reaches PhaseGameOver.
+type GameResult struct {
+	Factions []Faction `json:"factions"`
+	Winners  []Winner  `json:"winners"`
+	Round    int       `json:"round"`
+	Seed     int64     `json:"seed"`
+	EndedAt  time.Time `json:"endedAt"`
+}
+
+// HasWinner reports whether the given player is among the winners.
+func (r *GameResult) HasWinner(playerID string) bool {
+	for _, w := range r.Winners {
+		if w.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// killPlayers marks players dead and then evaluates the win conditions once,
+// so simultaneous deaths are judged together. It must be called for every
+// death source, and returns true when the game is over.
+func (g *Game) killPlayers(cause DeathCause, playerIDs ...string) bool {
+	for _, id := range playerIDs {
+		g.markDead(id, cause)
+	}
+
+	return g.evaluateWin()
+}
+
+// markDead records a single death without evaluating the win conditions.
+func (g *Game) markDead(playerID string, cause DeathCause) {
+	p, exists := g.Players[playerID]
+	if !exists || !p.IsAlive {
+		return
+	}
+	p.IsAlive = false
+	g.Deaths = append(g.Deaths, Death{PlayerID: playerID, Cause: cause, Round: g.Round})
+
+	if objectiveOf(p.Role) == ObjectiveLynched && cause == DeathLynch {
+		g.soloWinners = append(g.soloWinners, playerID)
+		g.log().Info("solo objective achieved", logging.KeyActor, p.ID, logging.KeyRole, p.Role)
+	}
+}
+
+// evaluateWin ends the game if a win condition has been met.
+func (g *Game) evaluateWin() bool {
+	if g.Phase == PhaseGameOver {
+		return true
+	}
+
+	factions, soloKillers, ok := g.winningSides()
+	if !ok {
+		return false
+	}
+
+	result := &GameResult{
+		Factions: factions,
+		Round:    g.Round,
+		Seed:     g.Seed,
+		EndedAt:  g.clock.Now(),
+	}
+	credited := make(map[string]bool)
+	credit := func(p *Player) {
+		if credited[p.ID] {
+			return
+		}
+		credited[p.ID] = true
+		result.Winners = append(result.Winners, Winner{
+			PlayerID: p.ID,
+			Name:     p.Name,
+			Role:     p.Role,
+			Faction:  FactionOf(p.Role),
+		})
+	}
+	for _, p := range g.Players {
+		if objectiveOf(p.Role) != ObjectiveFaction {
+			continue
+		}
+		for _, f := range factions {
+			if FactionOf(p.Role) == f {
+				credit(p)
+			}
+		}
+	}
+	for _, id := range soloKillers {
+		credit(g.Players[id])
+	}
+	for _, id := range g.soloWinners {
+		if p, exists := g.Players[id]; exists {
+			credit(p)
+		}
+	}
+	sort.Slice(result.Winners, func(i, j int) bool {
+		return result.Winners[i].PlayerID < result.Winners[j].PlayerID
+	})
+
+	g.Result = result
+	g.Phase = PhaseGameOver
+	g.log().Info("game over", "factions", factions, "winners", len(result.Winners))
+	return true
+}
+
+// winningSides determines whether the game has ended and which factions and
+// solo killers won. Only mafia and last-standing roles threaten the town.
+func (g *Game) winningSides() ([]Faction, []string, bool) {
+	if len(g.soloWinners) > 0 {
+		// A lynched jester ends the game on the spot; nobody else wins
+		return []Faction{FactionNeutral}, nil, true
+	}
+
+	var alive, mafia int
+	var killers []string
+	for _, p := range g.Players {
+		if !p.IsAlive {
+			continue
+		}
+		alive++
+		switch {
+		case p.IsMafia():
+			mafia++
+		case objectiveOf(p.Role) == ObjectiveLastStanding:
+			killers = append(killers, p.ID)
+		}
+	}
+
+	switch {
+	case alive == 0:
+		// Everyone died at once; nobody wins.
+		return []Faction{}, nil, true
+	case mafia == 0 && len(killers) == 0:
+		return []Faction{FactionTown}, nil, true
+	case len(killers) == 0 && mafia >= alive-mafia:
+		return []Faction{FactionMafia}, nil, true
+	case mafia == 0 && len(killers) == 1 && alive <= 2:
+		return []Faction{FactionNeutral}, killers, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/silent-vendetta/pkg/clock"
+)
+
+// newTestGame returns a game in its first night with a living player of each
+// given role, for calling the rule methods directly. It has no goroutine.
+func newTestGame(roles map[string]Role) *Game {
+	g := &Game{
+		State: State{
+			ID:           "test",
+			Players:      make(map[string]*Player, len(roles)),
+			Phase:        PhaseNight,
+			Round:        1,
+			GameSettings: DefaultSettings(),
+		},
+		clock:  clock.NewFake(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for id, role := range roles {
+		g.Players[id] = &Player{ID: id, Name: id, Role: role, IsAlive: true}
+	}
+	return g
+}
+
+// TestJesterLynchEndsGame checks that lynching the jester ends the game at
+// once with the jester as the only winner, even when the lynch also gives
+// the mafia parity.
+func TestJesterLynchEndsGame(t *testing.T) {
+	g := newTestGame(map[string]Role{
+		"ann": RoleMafia,
+		"ben": RoleJester,
+		"cal": RoleVillager,
+	})
+	g.Phase = PhaseVote
+	for _, id := range []string{"ann", "cal"} {
+		g.Players[id].VotedFor = "ben"
+	}
+
+	g.tallyVotes()
+
+	if g.Phase != PhaseGameOver || g.Result == nil {
+		t.Fatalf("game in phase %s after the jester was lynched", g.Phase)
+	}
+	if len(g.Result.Winners) != 1 || g.Result.Winners[0].PlayerID != "ben" {
+		t.Fatalf("winners %v, want only the jester", g.Result.Winners)
+	}
+	if len(g.Result.Factions) != 1 || g.Result.Factions[0] != FactionNeutral {
+		t.Fatalf("factions %v, want [neutral]", g.Result.Factions)
+	}
+}
+
+// TestEvaluateWin kills players and checks which factions and players, if
+// any, have won.
+func TestEvaluateWin(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles map[string]Role
+		dead  []string
+		// factions is nil while the game goes on.
+		factions []Faction
+		winners  []string
+	}{
+		{
+			name:  "game goes on",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleVillager},
+		},
+		{
+			name:     "town wins once the mafia is dead",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleDetective},
+			dead:     []string{"ann"},
+			factions: []Faction{FactionTown},
+			winners:  []string{"ben", "cal"},
+		},
+		{
+			name:     "dead town members still win",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleVillager, "cal": RoleMedic, "dee": RoleVillager},
+			dead:     []string{"ann", "ben"},
+			factions: []Faction{FactionTown},
+			winners:  []string{"ben", "cal", "dee"},
+		},
+		{
+			name:     "mafia wins at parity",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleGodfather, "cal": RoleVillager, "dee": RoleVillager, "eve": RoleMedic},
+			dead:     []string{"eve"},
+			factions: []Faction{FactionMafia},
+			winners:  []string{"ann", "ben"},
+		},
+		{
+			name:  "serial killer stops mafia parity",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleSerialKiller, "cal": RoleVillager},
+			dead:  []string{"cal"},
+		},
+		{
+			name:  "serial killer keeps the town from winning",
+			roles: map[string]Role{"ann": RoleMafia, "ben": RoleSerialKiller, "cal": RoleVillager, "dee": RoleVillager},
+			dead:  []string{"ann"},
+		},
+		{
+			name:     "serial killer wins last standing",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleSerialKiller, "cal": RoleVillager, "dee": RoleVillager},
+			dead:     []string{"ann", "cal"},
+			factions: []Faction{FactionNeutral},
+			winners:  []string{"ben"},
+		},
+		{
+			name:     "jester does not share a town win",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleJester, "cal": RoleVillager},
+			dead:     []string{"ann"},
+			factions: []Faction{FactionTown},
+			winners:  []string{"cal"},
+		},
+		{
+			name:     "nobody wins when everyone dies",
+			roles:    map[string]Role{"ann": RoleMafia, "ben": RoleVillager},
+			dead:     []string{"ann", "ben"},
+			factions: []Faction{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(tt.roles)
+
+			over := g.killPlayers(DeathNightKill, tt.dead...)
+
+			if tt.factions == nil {
+				if over {
+					t.Fatalf("game ended with factions %v", g.Result.Factions)
+				}
+				return
+			}
+			if !over || g.Phase != PhaseGameOver {
+				t.Fatalf("game still in phase %s", g.Phase)
+			}
+			if !reflect.DeepEqual(g.Result.Factions, tt.factions) {
+				t.Errorf("factions %v, want %v", g.Result.Factions, tt.factions)
+			}
+			var winners []string
+			for _, w := range g.Result.Winners {
+				winners = append(winners, w.PlayerID)
+			}
+			if !reflect.DeepEqual(winners, tt.winners) {
+				t.Errorf("winners %v, want %v", winners, tt.winners)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package logging builds the structured loggers used across the server and
+// names the attributes log lines are correlated by.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys shared by every log line about a game.
+const (
+	KeyGameID   = "game_id"
+	KeyPlayerID = "player_id"
+	KeyPhase    = "phase"
+	KeyRound    = "round"
+)
+
+// Attribute keys for information players must not learn from the logs. They
+// are redacted unless Config.Debug is set.
+const (
+	// KeyRole is a player's secret role.
+	KeyRole = "role"
+	// KeyActor is a player whose action gives their role away, such as
+	// using a night ability.
+	KeyActor = "actor"
+	// KeyTarget is the target of a night ability.
+	KeyTarget = "target"
+)
+
+// Redacted replaces the value of secret attributes.
+const Redacted = "[redacted]"
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config selects how logs are written.
+type Config struct {
+	// Level is the lowest level written: debug, info, warn or error.
+	Level string `json:"level"`
+	// Format is FormatText or FormatJSON.
+	Format string `json:"format"`
+	// Debug writes roles and night actions in the clear. Never set it where
+	// players can read the logs.
+	Debug bool `json:"debug"`
+}
+
+// DefaultConfig returns the settings the server logs with.
+func DefaultConfig() Config {
+	return Config{
+		Level:  "info",
+		Format: FormatText,
+	}
+}
+
+// Validate checks the level and format.
+func (c Config) Validate() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return fmt.Errorf("unknown level %q", c.Level)
+	}
+	switch strings.ToLower(c.Format) {
+	case FormatText, FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, use %s or %s", c.Format, FormatText, FormatJSON)
+	}
+}
+
+// New returns a logger writing to w. Invalid settings fall back to the
+// defaults.
+func New(w io.Writer, config Config) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.ToLower(config.Format) == FormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	if !config.Debug {
+		handler = Redact(handler)
+	}
+	return slog.New(handler)
+}
+
+// Default returns the default slog logger with secrets redacted, for
+// packages that were not given a logger.
+func Default() *slog.Logger {
+	return slog.New(Redact(slog.Default().Handler()))
+}
+
+// Redact wraps handler so the values of secret attributes are replaced by
+// Redacted.
+func Redact(handler slog.Handler) slog.Handler {
+	return redactHandler{handler}
+}
+
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redact(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+// redact hides the value of a secret attribute, looking inside groups.
+func redact(a slog.Attr) slog.Attr {
+	switch a.Key {
+	case KeyRole, KeyActor, KeyTarget:
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]interface{}, len(group))
+		for i, member := range group {
+			redacted[i] = redact(member)
+		}
+		return slog.Group(a.Key, redacted...)
+	}
+	return a
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// TestRedact writes log lines with secret attributes and checks what ends
+// up in the output.
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		log   func(*slog.Logger)
+		// want maps attribute paths, with groups joined by dots, to the
+		// value expected in the output.
+		want map[string]string
+	}{
+		{
+			name: "secret attributes",
+			log: func(l *slog.Logger) {
+				l.Info("night", KeyRole, "mafia", KeyActor, "ann", KeyTarget, "ben")
+			},
+			want: map[string]string{KeyRole: Redacted, KeyActor: Redacted, KeyTarget: Redacted},
+		},
+		{
+			name: "correlation attributes kept",
+			log: func(l *slog.Logger) {
+				l.Info("vote", KeyGameID, "g1", KeyPlayerID, "ann", KeyPhase, "vote", KeyTarget, "ben")
+			},
+			want: map[string]string{KeyGameID: "g1", KeyPlayerID: "ann", KeyPhase: "vote", KeyTarget: Redacted},
+		},
+		{
+			name: "inside groups",
+			log: func(l *slog.Logger) {
+				l.Info("night", slog.Group("action", KeyActor, "ann", "kind", "kill", slog.Group("on", KeyRole, "medic")))
+			},
+			want: map[string]string{"action.actor": Redacted, "action.kind": "kill", "action.on.role": Redacted},
+		},
+		{
+			name: "added with With",
+			log: func(l *slog.Logger) {
+				l.With(KeyRole, "godfather", KeyGameID, "g1").Info("joined")
+			},
+			want: map[string]string{KeyRole: Redacted, KeyGameID: "g1"},
+		},
+		{
+			name: "added under a group",
+			log: func(l *slog.Logger) {
+				l.WithGroup("game").With(KeyTarget, "ben").Info("blocked", KeyRole, "roleblocker")
+			},
+			want: map[string]string{"game.target": Redacted, "game.role": Redacted},
+		},
+		{
+			name:  "debug logs secrets",
+			debug: true,
+			log: func(l *slog.Logger) {
+				l.With(KeyRole, "mafia").Info("night", KeyActor, "ann", KeyTarget, "ben")
+			},
+			want: map[string]string{KeyRole: "mafia", KeyActor: "ann", KeyTarget: "ben"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(New(&buf, Config{Level: "info", Format: FormatJSON, Debug: tt.debug}))
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("parsing %q: %v", buf.String(), err)
+			}
+			got := make(map[string]string)
+			flatten("", line, got)
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q in %s", key, got[key], want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+// flatten collects the string values of a JSON log line by their dotted
+// path.
+func flatten(prefix string, values map[string]interface{}, into map[string]string) {
+	for key, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(prefix+key+".", v, into)
+		case string:
+			into[prefix+key] = v
+		}
+	}
+}
+
+// TestValidate checks the levels and formats a Config accepts.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "default", config: DefaultConfig()},
+		{name: "json debug", config: Config{Level: "debug", Format: "JSON"}},
+		{name: "offset level", config: Config{Level: "warn+2", Format: FormatText}},
+		{name: "unknown level", config: Config{Level: "loud", Format: FormatText}, wantErr: true},
+		{name: "unknown format", config: Config{Level: "info", Format: "xml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
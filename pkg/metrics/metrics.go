@@ -0,0 +1,210 @@
+// Package metrics collects Prometheus metrics about games and websocket
+// traffic.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "silent_vendetta"
+
+// Sources report current state. They are read every time the metrics are
+// scraped.
+type Sources struct {
+	// GamesByPhase counts the open games in each phase.
+	GamesByPhase func() map[string]int
+	// ClientsByKind counts the connected websocket clients of each kind.
+	ClientsByKind func() map[string]int
+}
+
+// Metrics holds the server's metrics and the registry they are served from.
+type Metrics struct {
+	registry         *prometheus.Registry
+	gamesStarted     prometheus.Counter
+	gamesFinished    *prometheus.CounterVec
+	messagesIn       *prometheus.CounterVec
+	messagesOut      *prometheus.CounterVec
+	writeErrors      *prometheus.CounterVec
+	rateLimited      *prometheus.CounterVec
+	phaseDuration    *prometheus.HistogramVec
+	broadcastLatency prometheus.Histogram
+
+	// phases holds when the current phase of each game began.
+	phases map[string]phaseStart
+	mu     sync.Mutex
+}
+
+type phaseStart struct {
+	phase string
+	at    time.Time
+}
+
+// New registers the metrics, along with the Go runtime and process
+// collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		gamesStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "games_started_total",
+			Help:      "Games that have dealt their roles and begun.",
+		}),
+		gamesFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "games_finished_total",
+			Help:      "Games that have ended, by winning faction. A game counts once for every faction that won it.",
+		}, []string{"faction"}),
+		messagesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_messages_received_total",
+			Help:      "Websocket messages received from clients, by type.",
+		}, []string{"type"}),
+		messagesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_messages_sent_total",
+			Help:      "Websocket messages sent to clients, by type.",
+		}, []string{"type"}),
+		writeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_write_errors_total",
+			Help:      "Websocket messages that could not be sent, by type.",
+		}, []string{"type"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_total",
+			Help:      "Requests and websocket messages refused for exceeding a rate limit, by limit.",
+		}, []string{"limit"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "How long game phases lasted, by phase.",
+			Buckets:   []float64{1, 5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600, 1800},
+		}, []string{"phase"}),
+		broadcastLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "broadcast_latency_seconds",
+			Help:      "How long sending a game state to everyone in the game took.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+		phases: make(map[string]phaseStart),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.gamesStarted,
+		m.gamesFinished,
+		m.messagesIn,
+		m.messagesOut,
+		m.writeErrors,
+		m.rateLimited,
+		m.phaseDuration,
+		m.broadcastLatency,
+	)
+	return m
+}
+
+// Track registers gauges read from sources. It must be called at most once.
+func (m *Metrics) Track(sources Sources) {
+	if sources.GamesByPhase != nil {
+		m.registry.MustRegister(newGaugeFunc("games_active", "Open games, by phase.", "phase", sources.GamesByPhase))
+	}
+	if sources.ClientsByKind != nil {
+		m.registry.MustRegister(newGaugeFunc("websocket_clients", "Connected websocket clients, by kind.", "kind", sources.ClientsByKind))
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// GameStarted counts a game dealing its roles.
+func (m *Metrics) GameStarted() {
+	m.gamesStarted.Inc()
+}
+
+// GameFinished counts a game ending. A game nobody won counts for the
+// faction "none".
+func (m *Metrics) GameFinished(factions []string) {
+	if len(factions) == 0 {
+		m.gamesFinished.WithLabelValues("none").Inc()
+	}
+	for _, faction := range factions {
+		m.gamesFinished.WithLabelValues(faction).Inc()
+	}
+}
+
+// PhaseChanged records that a game entered phase at the given time, and
+// how long the phase it left lasted.
+func (m *Metrics) PhaseChanged(gameID string, phase string, at time.Time) {
+	m.mu.Lock()
+	previous, exists := m.phases[gameID]
+	m.phases[gameID] = phaseStart{phase: phase, at: at}
+	m.mu.Unlock()
+
+	if exists {
+		m.phaseDuration.WithLabelValues(previous.phase).Observe(at.Sub(previous.at).Seconds())
+	}
+}
+
+// GameClosed forgets a game's phase timing.
+func (m *Metrics) GameClosed(gameID string) {
+	m.mu.Lock()
+	delete(m.phases, gameID)
+	m.mu.Unlock()
+}
+
+// MessageReceived counts a message from a client.
+func (m *Metrics) MessageReceived(msgType string) {
+	m.messagesIn.WithLabelValues(msgType).Inc()
+}
+
+// MessageSent counts a message written to a client.
+func (m *Metrics) MessageSent(msgType string) {
+	m.messagesOut.WithLabelValues(msgType).Inc()
+}
+
+// WriteFailed counts a message that could not be written to a client.
+func (m *Metrics) WriteFailed(msgType string) {
+	m.writeErrors.WithLabelValues(msgType).Inc()
+}
+
+// RateLimited counts a request or message refused by the named limit.
+func (m *Metrics) RateLimited(limit string) {
+	m.rateLimited.WithLabelValues(limit).Inc()
+}
+
+// BroadcastObserved records how long a game state broadcast took.
+func (m *Metrics) BroadcastObserved(d time.Duration) {
+	m.broadcastLatency.Observe(d.Seconds())
+}
+
+// gaugeFunc is a gauge with one label whose values are read at scrape time.
+type gaugeFunc struct {
+	desc   *prometheus.Desc
+	values func() map[string]int
+}
+
+func newGaugeFunc(name string, help string, label string, values func() map[string]int) *gaugeFunc {
+	return &gaugeFunc{
+		desc:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, []string{label}, nil),
+		values: values,
+	}
+}
+
+func (g *gaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	for label, value := range g.values() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(value), label)
+	}
+}
@@ -0,0 +1,186 @@
+// Package ratelimit limits how often clients may act, with a token bucket
+// for each client.
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/silent-vendetta/pkg/clock"
+)
+
+// Rate allows Events actions every Per, in bursts of up to Events. The zero
+// Rate allows everything.
+type Rate struct {
+	Events int
+	Per    time.Duration
+}
+
+// Every returns a Rate of events actions every per.
+func Every(events int, per time.Duration) Rate {
+	return Rate{Events: events, Per: per}
+}
+
+// Unlimited reports whether the rate allows everything.
+func (r Rate) Unlimited() bool {
+	return r.Events == 0
+}
+
+// Validate reports whether the rate can be enforced.
+func (r Rate) Validate() error {
+	switch {
+	case r.Events < 0:
+		return fmt.Errorf("events must not be negative")
+	case r.Events > 0 && r.Per <= 0:
+		return fmt.Errorf("period must be positive")
+	}
+	return nil
+}
+
+// String formats the rate as events/period, such as "5/1m0s".
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "0"
+	}
+	return strconv.Itoa(r.Events) + "/" + r.Per.String()
+}
+
+// UnmarshalText parses a rate written as events/period, such as "5/1m" or
+// "10/s". "0" allows everything.
+func (r *Rate) UnmarshalText(text []byte) error {
+	events, per, found := strings.Cut(strings.TrimSpace(string(text)), "/")
+	n, err := strconv.Atoi(events)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a rate such as 5/1m", text)
+	}
+	if n == 0 && !found {
+		*r = Rate{}
+		return nil
+	}
+	if !found {
+		return fmt.Errorf("%q is not a rate such as 5/1m", text)
+	}
+
+	// A bare unit means one of it, so "10/s" is ten a second
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("%q is not a rate such as 5/1m", text)
+	}
+	*r = Rate{Events: n, Per: d}
+	return nil
+}
+
+// UnmarshalJSON parses a rate from a JSON string, or the number 0, which
+// configuration files naturally use to turn a limit off.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	if string(data) == "0" {
+		*r = Rate{}
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("%s is not a rate such as \"5/1m\"", data)
+	}
+	return r.UnmarshalText([]byte(text))
+}
+
+// MarshalText formats the rate as events/period.
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter enforces a rate separately for every key, such as a client's IP.
+// A nil Limiter allows everything.
+type Limiter struct {
+	rate      Rate
+	clock     clock.Clock
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// New returns a Limiter enforcing rate, as measured by c.
+func New(rate Rate, c clock.Clock) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		clock:     c,
+		buckets:   make(map[string]*bucket),
+		lastPrune: c.Now(),
+	}
+}
+
+// Allow takes one action from key's allowance. If none is left it returns
+// false and how long until the next action is allowed.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.rate.Unlimited() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.prune(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(l.rate.Events), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration(math.Ceil((1 - b.tokens) * float64(l.rate.Per) / float64(l.rate.Events)))
+	return false, wait
+}
+
+// Forget drops the allowance kept for key.
+func (l *Limiter) Forget(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.buckets, key)
+	l.mu.Unlock()
+}
+
+// refill returns the tokens in b at now.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	tokens := b.tokens + float64(elapsed)*float64(l.rate.Events)/float64(l.rate.Per)
+	return math.Min(tokens, float64(l.rate.Events))
+}
+
+// prune drops the buckets that have refilled completely, as they are no
+// different from new ones. It runs at most once a period.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.rate.Per {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.rate.Events) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/silent-vendetta/pkg/clock"
+)
+
+// TestLimiter takes actions from a limiter while moving the clock, checking
+// which are allowed and how long a refused one is told to wait.
+func TestLimiter(t *testing.T) {
+	type step struct {
+		// advance moves the clock before the action.
+		advance time.Duration
+		key     string
+		allowed bool
+		wait    time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  Rate
+		steps []step
+	}{
+		{
+			name: "burst then refill",
+			rate: Every(2, time.Second),
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", wait: 500 * time.Millisecond},
+				{advance: 250 * time.Millisecond, key: "a", wait: 250 * time.Millisecond},
+				{advance: 250 * time.Millisecond, key: "a", allowed: true},
+				{key: "a", wait: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill stops at the burst",
+			rate: Every(2, time.Second),
+			steps: []step{
+				{key: "a", allowed: true},
+				{advance: time.Hour, key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", wait: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "keys have their own buckets",
+			rate: Every(1, time.Minute),
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", wait: time.Minute},
+				{key: "b", allowed: true},
+				{advance: 30 * time.Second, key: "b", wait: 30 * time.Second},
+			},
+		},
+		{
+			name: "pruned buckets start full",
+			rate: Every(1, time.Second),
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "b", allowed: true},
+				{advance: 2 * time.Second, key: "a", allowed: true},
+				{key: "b", allowed: true},
+				{key: "b", wait: time.Second},
+			},
+		},
+		{
+			name: "unlimited",
+			rate: Rate{},
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewFake(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC))
+			l := New(tt.rate, c)
+			for i, s := range tt.steps {
+				c.Advance(s.advance)
+				allowed, wait := l.Allow(s.key)
+				if allowed != s.allowed || wait != s.wait {
+					t.Fatalf("step %d: Allow(%q) = %v, %v; want %v, %v", i, s.key, allowed, wait, s.allowed, s.wait)
+				}
+			}
+		})
+	}
+}
+
+// TestNilLimiter checks that a nil limiter allows everything.
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	for i := 0; i < 3; i++ {
+		if allowed, wait := l.Allow("a"); !allowed || wait != 0 {
+			t.Fatalf("nil limiter refused action %d, wait %v", i, wait)
+		}
+	}
+	l.Forget("a")
+}
+
+// TestForget checks that a forgotten key starts over with a full bucket.
+func TestForget(t *testing.T) {
+	l := New(Every(1, time.Minute), clock.NewFake(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)))
+	if allowed, _ := l.Allow("a"); !allowed {
+		t.Fatal("first action refused")
+	}
+	if allowed, _ := l.Allow("a"); allowed {
+		t.Fatal("second action allowed")
+	}
+	l.Forget("a")
+	if allowed, _ := l.Allow("a"); !allowed {
+		t.Fatal("action refused after forgetting the key")
+	}
+}
+
+// TestParseRate checks the rates configuration files and flags are written
+// with.
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Rate
+		wantErr bool
+	}{
+		{text: "5/1m", want: Every(5, time.Minute)},
+		{text: "10/s", want: Every(10, time.Second)},
+		{text: "3/500ms", want: Every(3, 500*time.Millisecond)},
+		{text: " 2/h ", want: Every(2, time.Hour)},
+		{text: "0", want: Rate{}},
+		{text: "0/1m", want: Rate{Per: time.Minute}},
+		{text: "5", wantErr: true},
+		{text: "5/", wantErr: true},
+		{text: "5/0s", wantErr: true},
+		{text: "-1/s", wantErr: true},
+		{text: "five/s", wantErr: true},
+		{text: "5/fortnight", wantErr: true},
+		{text: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got Rate
+			err := got.UnmarshalText([]byte(tt.text))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UnmarshalText(%q) = %v, want an error", tt.text, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("UnmarshalText(%q) = %v, %v; want %v", tt.text, got, err, tt.want)
+			}
+			if tt.want.Unlimited() {
+				return
+			}
+
+			// Formatting a rate must give back text that parses to it
+			var again Rate
+			if err := again.UnmarshalText([]byte(got.String())); err != nil || again != got {
+				t.Fatalf("%q did not round trip: %v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/subtle"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/logging"
+)
+
+// AdminGame is an entry in the admin game list.
+type AdminGame struct {
+	game.GameSummary
+	Code         string    `json:"code"`
+	Round        int       `json:"round"`
+	AlivePlayers int       `json:"alivePlayers"`
+	PhaseEndTime time.Time `json:"phaseEndTime"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// registerHealth adds the liveness and readiness probes.
+func (s *Server) registerHealth(app *fiber.App) {
+	// The process is alive as long as it can answer
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": "ok",
+		})
+	})
+
+	// Traffic should only be sent once saved games are restored, and not
+	// while the server is shutting down
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		if !s.ready.Load() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status": "unavailable",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": "ok",
+			"games":  len(s.games.Snapshots()),
+		})
+	})
+}
+
+// registerAdmin adds the admin API, which is only served when an admin
+// token is configured.
+func (s *Server) registerAdmin(app *fiber.App) {
+	if s.config.AdminToken == "" {
+		return
+	}
+	gameManager, wsManager, logger := s.games, s.ws, s.logger
+
+	admin := app.Group("/admin", s.requireAdmin)
+
+	admin.Get("/games", func(c *fiber.Ctx) error {
+		snapshots := gameManager.Snapshots()
+		sort.Slice(snapshots, func(i, j int) bool {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		})
+
+		games := make([]AdminGame, len(snapshots))
+		for i, snapshot := range snapshots {
+			games[i] = AdminGame{
+				GameSummary:  snapshot.Summary(),
+				Code:         snapshot.Code,
+				Round:        snapshot.Round,
+				AlivePlayers: len(snapshot.AlivePlayers()),
+				PhaseEndTime: snapshot.PhaseEndTime,
+				UpdatedAt:    snapshot.UpdatedAt,
+			}
+		}
+		return c.JSON(fiber.Map{
+			"games": games,
+			"total": len(games),
+		})
+	})
+
+	// The full state includes roles, night targets and the replay seed, but
+	// not the lobby password or the players' tokens
+	admin.Get("/games/:id", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+		record, err := gameManager.Record(gameID)
+		if err != nil {
+			return err
+		}
+		record.PasswordHash = nil
+		for id, secrets := range record.Secrets {
+			secrets.Token = ""
+			record.Secrets[id] = secrets
+		}
+
+		return c.JSON(fiber.Map{
+			"game":    record,
+			"clients": wsManager.ListClients(gameID),
+		})
+	})
+
+	admin.Post("/games/:id/advance", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+		if err := gameManager.AdvancePhase(gameID); err != nil {
+			return err
+		}
+		logger.Info("admin advanced phase", logging.KeyGameID, gameID, "ip", c.IP())
+
+		response := fiber.Map{
+			"success": true,
+		}
+		if snapshot, err := gameManager.GetGame(gameID); err == nil {
+			response["phase"] = snapshot.Phase
+			response["round"] = snapshot.Round
+		}
+		return c.JSON(response)
+	})
+
+	// Terminating a game tells its clients why and disconnects them
+	admin.Delete("/games/:id", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+		if _, err := gameManager.GetGame(gameID); err != nil {
+			return err
+		}
+		gameManager.CloseGame(gameID, game.CloseReasonTerminated)
+		logger.Info("admin terminated game", logging.KeyGameID, gameID, "ip", c.IP())
+
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	admin.Get("/clients", func(c *fiber.Ctx) error {
+		clients := wsManager.ListClients(c.Query("gameId"))
+		return c.JSON(fiber.Map{
+			"clients": clients,
+			"total":   len(clients),
+		})
+	})
+
+	admin.Delete("/clients/:id", func(c *fiber.Ctx) error {
+		clientID := c.Params("id")
+		if !wsManager.Disconnect(clientID, "disconnected by an administrator") {
+			return errClientNotFound
+		}
+		logger.Info("admin disconnected client", "client_id", clientID, "ip", c.IP())
+
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+}
+
+// requireAdmin rejects requests without the admin bearer token.
+func (s *Server) requireAdmin(c *fiber.Ctx) error {
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		s.logger.Warn("admin request rejected", "ip", c.IP(), "path", c.Path())
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="admin"`)
+		return errUnauthorized
+	}
+	return c.Next()
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/silent-vendetta/pkg/game"
+)
+
+// Codes for the errors the server reports itself, alongside the game's.
+const (
+	CodeInvalidBody         game.Code = "invalid_body"
+	CodeInvalidMessage      game.Code = "invalid_message"
+	CodeRateLimited         game.Code = "rate_limited"
+	CodeUnauthorized        game.Code = "unauthorized"
+	CodeClientNotFound      game.Code = "client_not_found"
+	CodeSpectatorNotAllowed game.Code = "spectator_not_allowed"
+)
+
+var (
+	errClientNotFound  = &game.GameError{Code: CodeClientNotFound, Err: errors.New("client not found")}
+	errUnauthorized    = &game.GameError{Code: CodeUnauthorized, Err: errors.New("admin token required")}
+	errPrivateSpectate = &game.GameError{Code: CodeSpectatorNotAllowed, Err: errors.New("only public games can be spectated")}
+	errSpectatorsChat  = &game.GameError{Code: CodeSpectatorNotAllowed, Err: errors.New("spectators can only chat")}
+	errTooManyMessages = &game.GameError{Code: CodeRateLimited, Err: errors.New("too many messages, slow down")}
+	errInvalidMessage  = &game.GameError{Code: CodeInvalidMessage, Err: errors.New("invalid message")}
+	errChatBeforeJoin  = &game.GameError{Code: game.CodePlayerNotFound, Err: errors.New("join the game before chatting")}
+	errInvalidJoin     = &game.GameError{Code: CodeInvalidMessage, Err: errors.New("join needs a playerName and token")}
+)
+
+// statuses are the HTTP statuses errors are reported with, by code. Codes
+// not listed are reported as 400 Bad Request.
+var statuses = map[game.Code]int{
+	game.CodeGameNotFound:   fiber.StatusNotFound,
+	game.CodePlayerNotFound: fiber.StatusNotFound,
+	CodeClientNotFound:      fiber.StatusNotFound,
+
+	game.CodeGameFull:           fiber.StatusConflict,
+	game.CodeInvalidPhase:       fiber.StatusConflict,
+	game.CodeNotEnoughPlayers:   fiber.StatusConflict,
+	game.CodeGameAlreadyStarted: fiber.StatusConflict,
+	game.CodePlayerNameTaken:    fiber.StatusConflict,
+	game.CodePlayerExists:       fiber.StatusConflict,
+	game.CodeNoShotsLeft:        fiber.StatusConflict,
+	game.CodeCannotReveal:       fiber.StatusConflict,
+
+	game.CodePlayerNotAlive: fiber.StatusForbidden,
+	game.CodeNotMafia:       fiber.StatusForbidden,
+	game.CodeNoNightAction:  fiber.StatusForbidden,
+	game.CodeNotHost:        fiber.StatusForbidden,
+	CodeSpectatorNotAllowed: fiber.StatusForbidden,
+
+	game.CodeInvalidPassword: fiber.StatusUnauthorized,
+	game.CodeInvalidToken:    fiber.StatusUnauthorized,
+	CodeUnauthorized:         fiber.StatusUnauthorized,
+
+	game.CodeInvalidVote:        fiber.StatusUnprocessableEntity,
+	game.CodeInvalidTarget:      fiber.StatusUnprocessableEntity,
+	game.CodeInvalidSettings:    fiber.StatusUnprocessableEntity,
+	game.CodeInvalidGameName:    fiber.StatusUnprocessableEntity,
+	game.CodeInvalidVisibility:  fiber.StatusUnprocessableEntity,
+	game.CodePasswordTooLong:    fiber.StatusUnprocessableEntity,
+	game.CodeUnknownBotStrategy: fiber.StatusUnprocessableEntity,
+	game.CodeInvalidPlayerName:  fiber.StatusUnprocessableEntity,
+	game.CodeReservedPlayerName: fiber.StatusUnprocessableEntity,
+
+	game.CodeTooManyAttempts: fiber.StatusTooManyRequests,
+	game.CodeTooManyOwnGames: fiber.StatusTooManyRequests,
+	CodeRateLimited:          fiber.StatusTooManyRequests,
+
+	game.CodeTooManyGames:    fiber.StatusServiceUnavailable,
+	game.CodeNoCodeAvailable: fiber.StatusServiceUnavailable,
+}
+
+// handleError is the Fiber error handler. Every error is reported as JSON
+// with a message and a code, along with the invalid fields of the request if
+// there are any. Errors that are not GameErrors are logged and reported as
+// 500 without details.
+func (s *Server) handleError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code := game.Code(strings.ToLower(strings.ReplaceAll(http.StatusText(fiberErr.Code), " ", "_")))
+		return c.Status(fiberErr.Code).JSON(errorBody(code, fiberErr.Message))
+	}
+
+	code := game.ErrorCode(err)
+	if code == game.CodeInternal {
+		s.logger.Error("request failed", "method", c.Method(), "path", c.Path(), "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorBody(code, "internal server error"))
+	}
+
+	status, listed := statuses[code]
+	if !listed {
+		status = fiber.StatusBadRequest
+	}
+	body := errorBody(code, err.Error())
+	if fields := game.FieldErrors(err); len(fields) > 0 {
+		messages := make([]string, len(fields))
+		details := make([]fiber.Map, len(fields))
+		for i, field := range fields {
+			messages[i] = field.Error()
+			details[i] = fiber.Map{
+				"field":  field.Field,
+				"reason": field.Reason,
+				"code":   game.ErrorCode(field),
+			}
+		}
+		body["error"] = strings.Join(messages, "; ")
+		body["fields"] = details
+	}
+	return c.Status(status).JSON(body)
+}
+
+// errorBody is the JSON body of an error response.
+func errorBody(code game.Code, message string) fiber.Map {
+	return fiber.Map{
+		"error": message,
+		"code":  code,
+	}
+}
+
+// parseBody decodes the request body into out.
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return &game.GameError{Code: CodeInvalidBody, Err: fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/logging"
+	"github.com/silent-vendetta/pkg/websocket"
+)
+
+type CreateGameRequest struct {
+	PlayerName string             `json:"playerName"`
+	Name       string             `json:"name,omitempty"`
+	Visibility game.Visibility    `json:"visibility,omitempty"`
+	Password   string             `json:"password,omitempty"`
+	Settings   *game.GameSettings `json:"settings,omitempty"`
+}
+
+// HostRequest identifies the host making a request, with the token they
+// were seated with.
+type HostRequest struct {
+	PlayerID string `json:"playerId"`
+	Token    string `json:"token"`
+}
+
+type AddBotsRequest struct {
+	HostRequest
+	Strategy string `json:"strategy,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+type UpdateSettingsRequest struct {
+	HostRequest
+	game.GameSettings
+}
+
+type RematchRequest struct {
+	HostRequest
+}
+
+type StartGameRequest struct {
+	HostRequest
+}
+
+type JoinGameRequest struct {
+	PlayerName string `json:"playerName"`
+	Password   string `json:"password,omitempty"`
+}
+
+// Validate reports every invalid field of the request.
+func (r CreateGameRequest) Validate() error {
+	errs := []error{r.options("").Validate()}
+	if _, err := game.NormalizePlayerName(r.PlayerName); err != nil {
+		errs = append(errs, err)
+	}
+	if r.Settings != nil {
+		errs = append(errs, settingsError(r.Settings.Validate()))
+	}
+	return errors.Join(errs...)
+}
+
+// options returns the lobby options for a request from the given IP.
+func (r CreateGameRequest) options(ip string) game.GameOptions {
+	return game.GameOptions{
+		Name:       r.Name,
+		Visibility: r.Visibility,
+		Password:   r.Password,
+		Owner:      ip,
+	}
+}
+
+// Validate reports every invalid field of the request.
+func (r JoinGameRequest) Validate() error {
+	var errs []error
+	if _, err := game.NormalizePlayerName(r.PlayerName); err != nil {
+		errs = append(errs, err)
+	}
+	if len(r.Password) > game.MaxPasswordLength {
+		errs = append(errs, &game.FieldError{Field: "password", Reason: fmt.Sprintf("must be at most %d bytes", game.MaxPasswordLength), Err: game.ErrPasswordTooLong})
+	}
+	return errors.Join(errs...)
+}
+
+// settingsError reports invalid settings as a field error.
+func settingsError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &game.FieldError{Field: "settings", Reason: "are not a playable combination", Err: err}
+}
+
+// registerAPI adds the REST routes. Handlers return errors as they are;
+// handleError turns them into responses.
+func (s *Server) registerAPI(app *fiber.App) {
+	gameManager, wsManager, bots, logger := s.games, s.ws, s.bots, s.logger
+
+	// API routes
+	app.Post("/api/games", s.limitByIP("create", s.createLimit), func(c *fiber.Ctx) error {
+		var req CreateGameRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+
+		// Check the whole request first so a lobby is never opened with bad
+		// settings or without its host
+		if err := req.Validate(); err != nil {
+			return err
+		}
+		playerName, _ := game.NormalizePlayerName(req.PlayerName)
+
+		created, err := gameManager.CreateGame(req.options(c.IP()))
+		if err != nil {
+			return err
+		}
+
+		if req.Settings != nil {
+			if err := gameManager.UpdateSettings(created.ID, *req.Settings); err != nil {
+				gameManager.RemoveGame(created.ID)
+				return err
+			}
+		}
+
+		// Add the host player. Players are identified by their normalized
+		// name, which is also what their websocket join resolves to, and
+		// prove who they are with the token.
+		token, err := gameManager.SeatPlayer(created.ID, playerName, playerName)
+		if err != nil {
+			gameManager.RemoveGame(created.ID)
+			return err
+		}
+
+		// Send initial player count
+		wsManager.SendToGame(created.ID, websocket.Message{
+			Type: "playerCount",
+			Data: 1,
+		})
+
+		return c.JSON(fiber.Map{
+			"gameId":     created.ID,
+			"code":       created.Code,
+			"playerId":   playerName,
+			"playerName": playerName,
+			"token":      token,
+		})
+	})
+
+	app.Get("/api/games", func(c *fiber.Ctx) error {
+		return c.JSON(lobbyListing(gameManager, game.ListFilter{
+			Query:    c.Query("q"),
+			OpenOnly: c.QueryBool("open"),
+			Offset:   c.QueryInt("offset"),
+			Limit:    c.QueryInt("limit"),
+		}))
+	})
+
+	app.Get("/api/games/by-code/:code", func(c *fiber.Ctx) error {
+		game, err := gameManager.GetGameByCode(c.Params("code"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(fiber.Map{
+			"gameId": game.ID,
+			"code":   game.Code,
+			"game":   game.Summary(),
+		})
+	})
+
+	app.Post("/api/games/:id/join", s.limitByIP("join", s.joinLimit), func(c *fiber.Ctx) error {
+		// Players may join by game ID or by join code
+		gameID, err := gameManager.ResolveGameID(c.Params("id"))
+		if err != nil {
+			return err
+		}
+		logger.Debug("join requested", logging.KeyGameID, gameID)
+
+		var req JoinGameRequest
+		if err := parseBody(c, &req); err != nil {
+			logger.Warn("invalid join request", logging.KeyGameID, gameID, "error", err)
+			return err
+		}
+		if err := req.Validate(); err != nil {
+			return err
+		}
+
+		if err := gameManager.CheckPassword(gameID, c.IP(), req.Password); err != nil {
+			logger.Warn("join rejected", logging.KeyGameID, gameID, logging.KeyPlayerID, req.PlayerName, "ip", c.IP(), "error", err)
+			return err
+		}
+
+		playerName, _ := game.NormalizePlayerName(req.PlayerName)
+		token, err := gameManager.SeatPlayer(gameID, playerName, playerName)
+		if err != nil {
+			logger.Warn("adding player failed", logging.KeyGameID, gameID, logging.KeyPlayerID, req.PlayerName, "error", err)
+			return err
+		}
+
+		game, _ := gameManager.GetGame(gameID)
+
+		logger.Info("player joined", logging.KeyGameID, gameID, logging.KeyPlayerID, playerName, "players", len(game.Players))
+
+		// Broadcast updated game state and player count
+		s.broadcastGameState(game)
+		wsManager.SendToGame(gameID, websocket.Message{
+			Type: "playerCount",
+			Data: len(game.Players),
+		})
+
+		return c.JSON(fiber.Map{
+			"success":    true,
+			"message":    "Successfully joined game",
+			"gameId":     gameID,
+			"playerId":   playerName,
+			"playerName": playerName,
+			"token":      token,
+		})
+	})
+
+	app.Post("/api/games/:id/start", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+
+		var req StartGameRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+
+		// Only the host may start the game
+		if err := gameManager.Authenticate(gameID, req.PlayerID, req.Token); err != nil {
+			return err
+		}
+		if err := gameManager.StartGameAsHost(gameID, req.PlayerID); err != nil {
+			logger.Warn("starting game failed", logging.KeyGameID, gameID, "error", err)
+			return err
+		}
+
+		logger.Info("game started", logging.KeyGameID, gameID)
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Game started successfully",
+		})
+	})
+
+	app.Post("/api/games/:id/settings", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+
+		var req UpdateSettingsRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+
+		if err := req.GameSettings.Validate(); err != nil {
+			return settingsError(err)
+		}
+		// Only the host may change the lobby
+		if err := gameManager.Authenticate(gameID, req.PlayerID, req.Token); err != nil {
+			return err
+		}
+		if err := gameManager.UpdateSettingsAsHost(gameID, req.PlayerID, req.GameSettings); err != nil {
+			logger.Warn("updating settings failed", logging.KeyGameID, gameID, logging.KeyPlayerID, req.PlayerID, "error", err)
+			return err
+		}
+
+		game, _ := gameManager.GetGame(gameID)
+		s.broadcastGameState(game)
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Game settings updated",
+		})
+	})
+
+	// The host can fill empty seats with bots
+	app.Post("/api/games/:id/bots", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+
+		var req AddBotsRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+
+		strategy, err := game.BotStrategyByName(req.Strategy)
+		if err != nil {
+			return &game.FieldError{Field: "strategy", Reason: "is not a known bot strategy", Err: err}
+		}
+		if req.Count <= 0 {
+			req.Count = 1
+		}
+		if err := gameManager.Authenticate(gameID, req.PlayerID, req.Token); err != nil {
+			return err
+		}
+
+		botIDs := make([]string, 0, req.Count)
+		for i := 0; i < req.Count; i++ {
+			botID, err := bots.Add(gameID, req.PlayerID, strategy)
+			if err != nil {
+				logger.Warn("adding bot failed", logging.KeyGameID, gameID, logging.KeyPlayerID, req.PlayerID, "error", err)
+				if len(botIDs) > 0 {
+					break
+				}
+				return err
+			}
+			botIDs = append(botIDs, botID)
+		}
+
+		game, _ := gameManager.GetGame(gameID)
+		s.broadcastGameState(game)
+		wsManager.SendToGame(gameID, websocket.Message{
+			Type: "playerCount",
+			Data: len(game.Players),
+		})
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"botIds":  botIDs,
+		})
+	})
+
+	// The host can take a finished game back to the lobby to play again
+	app.Post("/api/games/:id/rematch", func(c *fiber.Ctx) error {
+		gameID := c.Params("id")
+
+		var req RematchRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		if err := gameManager.Authenticate(gameID, req.PlayerID, req.Token); err != nil {
+			return err
+		}
+
+		if err := gameManager.Rematch(gameID, req.PlayerID); err != nil {
+			logger.Warn("starting rematch failed", logging.KeyGameID, gameID, logging.KeyPlayerID, req.PlayerID, "error", err)
+			return err
+		}
+
+		// The new lobby state itself is pushed by the phase change listener
+		wsManager.SendToGame(gameID, websocket.Message{
+			Type:   "rematch",
+			GameID: gameID,
+		})
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Rematch lobby opened",
+		})
+	})
+}
@@ -0,0 +1,425 @@
+// Package server wires the game and websocket managers to HTTP routes.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/silent-vendetta/pkg/clock"
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/logging"
+	"github.com/silent-vendetta/pkg/metrics"
+	"github.com/silent-vendetta/pkg/ratelimit"
+	"github.com/silent-vendetta/pkg/store"
+	"github.com/silent-vendetta/pkg/websocket"
+)
+
+// Config tunes the server.
+type Config struct {
+	// Addr is the address the server listens on.
+	Addr string
+	// TLSCertFile and TLSKeyFile, if both set, make the server listen with
+	// TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// AllowedOrigins are the origins browsers may call the API and open
+	// websockets from. Empty allows every origin.
+	AllowedOrigins []string
+	// ReadTimeout, WriteTimeout and IdleTimeout bound HTTP connections. Zero
+	// means no limit.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// SpectatorDelay is how long omniscient spectators lag behind the game.
+	SpectatorDelay time.Duration
+	// Bots controls how bot players pace their actions.
+	Bots game.BotConfig
+	// ShutdownTimeout bounds the graceful shutdown started when the context
+	// passed to Start is cancelled.
+	ShutdownTimeout time.Duration
+	// Limits protect the server from clients that flood it.
+	Limits Limits
+	// AdminToken is the bearer token the /admin API requires. The admin API
+	// is disabled while it is empty.
+	AdminToken string
+	// DisableStartupMessage hides the banner Fiber prints when it starts.
+	DisableStartupMessage bool
+}
+
+// Limits cap how fast clients may use the server. The zero value means no
+// limits.
+type Limits struct {
+	// CreateGame and JoinGame limit how often each IP address may create
+	// and join games.
+	CreateGame ratelimit.Rate
+	JoinGame   ratelimit.Rate
+	// Messages limits how often each websocket connection may send a
+	// message. Messages over the limit are dropped.
+	Messages ratelimit.Rate
+	// MaxMessageSize is the largest websocket message accepted, in bytes.
+	// Connections sending larger ones are closed. Zero means no limit.
+	MaxMessageSize int
+}
+
+// DefaultConfig returns the settings used by the server command.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":3001",
+		SpectatorDelay:  time.Minute,
+		Bots:            game.DefaultBotConfig(),
+		ShutdownTimeout: 10 * time.Second,
+		Limits: Limits{
+			CreateGame:     ratelimit.Every(5, time.Minute),
+			JoinGame:       ratelimit.Every(20, time.Minute),
+			Messages:       ratelimit.Every(10, time.Second),
+			MaxMessageSize: 4096,
+		},
+	}
+}
+
+// Dependencies are the services a Server is built on. Games and WebSocket
+// are required; the websocket manager must be started by the caller.
+type Dependencies struct {
+	Games     *game.GameManager
+	WebSocket *websocket.Manager
+	// Store saves the running games on shutdown and restores them on
+	// start. Without one, games are lost when the server stops.
+	Store store.Store
+	// Clock defaults to the clock of the game manager.
+	Clock clock.Clock
+	// Logger defaults to the default slog logger, with secrets redacted.
+	Logger *slog.Logger
+	// Metrics are served on /metrics. Pass the same metrics to the
+	// websocket manager with websocket.WithObserver to count sent
+	// messages. Defaults to a fresh set.
+	Metrics *metrics.Metrics
+}
+
+// Server serves the REST API and the websocket feeds for a GameManager.
+type Server struct {
+	config  Config
+	games   *game.GameManager
+	ws      *websocket.Manager
+	store   store.Store
+	clock   clock.Clock
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	bots    *game.Bots
+	app     *fiber.App
+	// createLimit and joinLimit are kept per IP, messageLimit per
+	// websocket client.
+	createLimit  *ratelimit.Limiter
+	joinLimit    *ratelimit.Limiter
+	messageLimit *ratelimit.Limiter
+	// ready is set once Start has restored the saved games, and cleared
+	// when the server starts shutting down.
+	ready atomic.Bool
+}
+
+// New builds the server's routes and subscribes it to game events.
+func New(config Config, deps Dependencies) *Server {
+	s := &Server{
+		config:  config,
+		games:   deps.Games,
+		ws:      deps.WebSocket,
+		store:   deps.Store,
+		clock:   deps.Clock,
+		logger:  deps.Logger,
+		metrics: deps.Metrics,
+		bots:    game.NewBots(deps.Games, config.Bots),
+	}
+	s.app = fiber.New(fiber.Config{
+		DisableStartupMessage: config.DisableStartupMessage,
+		ReadTimeout:           config.ReadTimeout,
+		WriteTimeout:          config.WriteTimeout,
+		IdleTimeout:           config.IdleTimeout,
+		ErrorHandler:          s.handleError,
+	})
+	if s.clock == nil {
+		s.clock = deps.Games.Clock()
+	}
+	if s.logger == nil {
+		s.logger = logging.Default()
+	}
+	if s.metrics == nil {
+		s.metrics = metrics.New()
+	}
+	s.createLimit = ratelimit.New(config.Limits.CreateGame, s.clock)
+	s.joinLimit = ratelimit.New(config.Limits.JoinGame, s.clock)
+	s.messageLimit = ratelimit.New(config.Limits.Messages, s.clock)
+	s.metrics.Track(metrics.Sources{
+		GamesByPhase:  s.gamesByPhase,
+		ClientsByKind: s.ws.CountClients,
+	})
+
+	// Enable CORS
+	corsConfig := cors.ConfigDefault
+	if len(config.AllowedOrigins) > 0 {
+		corsConfig.AllowOrigins = strings.Join(config.AllowedOrigins, ",")
+	}
+	s.app.Use(cors.New(corsConfig))
+
+	s.games.Subscribe(s.handleEvent)
+	s.app.Get("/metrics", adaptor.HTTPHandler(s.metrics.Handler()))
+	s.registerHealth(s.app)
+	s.registerAdmin(s.app)
+	s.registerAPI(s.app)
+	s.registerWebSocket(s.app)
+	return s
+}
+
+// Routes returns the Fiber app serving the REST API and websockets, for
+// embedding the server or serving it from another listener. Only servers
+// run with Start report themselves ready on /readyz.
+func (s *Server) Routes() *fiber.App {
+	return s.app
+}
+
+// Start restores the saved games and serves on the configured address until
+// ctx is cancelled, then shuts down gracefully.
+func (s *Server) Start(ctx context.Context) error {
+	if s.store != nil {
+		records, err := s.store.Load()
+		if err != nil {
+			return fmt.Errorf("loading saved games: %w", err)
+		}
+		if err := s.games.Restore(records); err != nil {
+			return fmt.Errorf("restoring saved games: %w", err)
+		}
+		if len(records) > 0 {
+			s.logger.Info("restored saved games", "games", len(records))
+		}
+	}
+
+	s.ready.Store(true)
+
+	tls := s.config.TLSCertFile != "" && s.config.TLSKeyFile != ""
+	s.logger.Info("listening", "addr", s.config.Addr, "tls", tls)
+
+	errc := make(chan error, 1)
+	go func() {
+		if tls {
+			errc <- s.app.ListenTLS(s.config.Addr, s.config.TLSCertFile, s.config.TLSKeyFile)
+			return
+		}
+		errc <- s.app.Listen(s.config.Addr)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+// Shutdown tells every websocket client the server is going away and sends
+// them a close frame, stops serving HTTP and saves the running games.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.ready.Store(false)
+	s.logger.Info("shutting down, draining websocket clients")
+	s.ws.CloseAll(websocket.Message{
+		Type: "serverShutdown",
+	})
+
+	err := s.app.ShutdownWithContext(ctx)
+
+	if s.store != nil {
+		records := s.games.Records()
+		if saveErr := s.store.Save(records); saveErr != nil {
+			return errors.Join(err, fmt.Errorf("saving games: %w", saveErr))
+		}
+		s.logger.Info("saved games", "games", len(records))
+	}
+	return err
+}
+
+// handleEvent pushes phase changes, including those made by the games' own
+// phase timers, to every client in the game.
+func (s *Server) handleEvent(event game.Event) {
+	s.recordEvent(event)
+
+	switch event.Type {
+	case game.EventPhaseChanged:
+		s.broadcastGameState(event.Snapshot)
+		sendInvestigationResults(s.ws, event.Snapshot)
+	case game.EventGameClosed:
+		s.ws.CloseRoom(event.GameID, websocket.Message{
+			Type:   "gameClosed",
+			GameID: event.GameID,
+			Data:   event.Reason,
+		})
+	}
+
+	if affectsLobby(event) {
+		s.ws.SendToGame(websocket.LobbyRoom, websocket.Message{
+			Type:   "lobbyGames",
+			GameID: websocket.LobbyRoom,
+			Data:   lobbyListing(s.games, game.ListFilter{}),
+		})
+	}
+}
+
+// recordEvent updates the metrics for a game event.
+func (s *Server) recordEvent(event game.Event) {
+	switch event.Type {
+	case game.EventGameCreated:
+		s.metrics.PhaseChanged(event.GameID, string(event.Snapshot.Phase), s.clock.Now())
+	case game.EventPhaseChanged:
+		snapshot := event.Snapshot
+		s.metrics.PhaseChanged(snapshot.ID, string(snapshot.Phase), s.clock.Now())
+		switch {
+		case snapshot.Phase == game.PhaseNight && snapshot.Round == 1:
+			s.metrics.GameStarted()
+		case snapshot.Phase == game.PhaseGameOver && snapshot.Result != nil:
+			factions := make([]string, len(snapshot.Result.Factions))
+			for i, faction := range snapshot.Result.Factions {
+				factions[i] = string(faction)
+			}
+			s.metrics.GameFinished(factions)
+		}
+	case game.EventGameRemoved:
+		s.metrics.GameClosed(event.GameID)
+	}
+}
+
+// gamesByPhase counts the open games in each phase.
+func (s *Server) gamesByPhase() map[string]int {
+	counts := make(map[string]int, len(game.Phases))
+	for _, phase := range game.Phases {
+		counts[string(phase)] = 0
+	}
+	for _, snapshot := range s.games.Snapshots() {
+		counts[string(snapshot.Phase)]++
+	}
+	return counts
+}
+
+// sendInvestigationResults privately tells each detective what they learned
+// during the night that just ended.
+func sendInvestigationResults(wsManager *websocket.Manager, g *game.Snapshot) {
+	if g.Phase != game.PhaseDiscuss {
+		return
+	}
+
+	for playerID := range g.Players {
+		results := g.PlayerInvestigations(playerID)
+		if len(results) == 0 || results[len(results)-1].Round != g.Round {
+			continue
+		}
+		wsManager.SendToPlayer(g.ID, playerID, websocket.Message{
+			Type:   "investigationResult",
+			GameID: g.ID,
+			Data:   results[len(results)-1],
+		})
+	}
+}
+
+// broadcastGameState sends each player their own view of the game state
+// right away, and a redacted copy to live spectators and to connections that
+// have not joined as a seated player. Omniscient spectators get the full
+// state only after the spectator delay, so a streamed game cannot be used to
+// cheat.
+func (s *Server) broadcastGameState(g *game.Snapshot) {
+	start := time.Now()
+	message := websocket.Message{
+		Type:   "gameState",
+		GameID: g.ID,
+		Data:   g,
+	}
+	s.ws.SendToPlayers(g.ID, func(c *websocket.Client) websocket.Message {
+		return websocket.Message{
+			Type:   "gameState",
+			GameID: g.ID,
+			Data:   g.PlayerView(c.PlayerID),
+		}
+	})
+	s.ws.SendToSpectators(g.ID, websocket.ViewRedacted, websocket.Message{
+		Type:   "gameState",
+		GameID: g.ID,
+		Data:   g.SpectatorView(),
+	})
+	s.metrics.BroadcastObserved(time.Since(start))
+
+	s.clock.AfterFunc(s.config.SpectatorDelay, func() {
+		s.ws.SendToSpectators(g.ID, websocket.ViewOmniscient, message)
+	})
+}
+
+// limitByIP refuses requests from IP addresses that have used up their
+// allowance from limiter, telling them when to retry.
+func (s *Server) limitByIP(name string, limiter *ratelimit.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed, wait := limiter.Allow(c.IP())
+		if allowed {
+			return c.Next()
+		}
+		s.metrics.RateLimited(name)
+		s.logger.Warn("rate limited", "limit", name, "ip", c.IP())
+
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		body := errorBody(CodeRateLimited, fmt.Sprintf("too many requests, try again in %ds", seconds))
+		body["retryAfter"] = seconds
+		return c.Status(fiber.StatusTooManyRequests).JSON(body)
+	}
+}
+
+// originAllowed reports whether a browser at origin may connect. Requests
+// without an Origin header do not come from a browser and are always allowed.
+func (s *Server) originAllowed(origin string) bool {
+	if origin == "" || len(s.config.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range s.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDead reports whether playerID is a player of the game who has died.
+func isDead(g *game.Snapshot, playerID string) bool {
+	p, exists := g.Players[playerID]
+	return exists && !p.IsAlive
+}
+
+// lobbyListing returns the first page of public lobbies waiting for players.
+func lobbyListing(gameManager *game.GameManager, filter game.ListFilter) fiber.Map {
+	filter.Visibility = game.VisibilityPublic
+	filter.Phase = game.PhaseWaiting
+	games, total := gameManager.ListGames(filter)
+	return fiber.Map{
+		"games": games,
+		"total": total,
+	}
+}
+
+// affectsLobby reports whether an event changes the public lobby listing:
+// public games being created, filling up, starting or removed.
+func affectsLobby(event game.Event) bool {
+	if event.Snapshot == nil || event.Snapshot.Visibility != game.VisibilityPublic {
+		return false
+	}
+	if event.Type == game.EventGameClosed {
+		// Closed games were already taken off by their removal
+		return false
+	}
+	if event.Type == game.EventPhaseChanged {
+		return event.Snapshot.Phase == game.PhaseWaiting || event.Snapshot.Round == 1
+	}
+	return true
+}
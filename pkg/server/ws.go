@@ -0,0 +1,348 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	fasthttpWs "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	fiberWs "github.com/gofiber/websocket/v2"
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/logging"
+	"github.com/silent-vendetta/pkg/websocket"
+)
+
+// messageTypes are the messages clients may send. Others are counted as
+// unknown, so clients cannot flood the metrics with made-up types.
+var messageTypes = map[string]bool{
+	"join":        true,
+	"mafiaAction": true,
+	"nightAction": true,
+	"vote":        true,
+	"reveal":      true,
+	"forfeit":     true,
+	"chat":        true,
+}
+
+// registerWebSocket adds the lobby and game websocket routes.
+func (s *Server) registerWebSocket(app *fiber.App) {
+	gameManager, wsManager, logger := s.games, s.ws, s.logger
+
+	// WebSocket setup
+	app.Use("/ws", func(c *fiber.Ctx) error {
+		if fiberWs.IsWebSocketUpgrade(c) {
+			// CORS does not apply to websockets, so check the origin here
+			if !s.originAllowed(c.Get(fiber.HeaderOrigin)) {
+				return fiber.ErrForbidden
+			}
+			c.Locals("allowed", true)
+			return c.Next()
+		}
+		return fiber.ErrUpgradeRequired
+	})
+
+	// The lobby feed pushes the public game list whenever it changes
+	app.Get("/ws/lobby", fiberWs.New(func(c *fiberWs.Conn) {
+		client := wsManager.NewClient(c, websocket.LobbyRoom)
+		s.limitMessageSize(c)
+		wsManager.Register <- client
+		defer func() {
+			wsManager.Unregister <- client
+		}()
+
+		client.WriteJSON(websocket.Message{
+			Type:   "lobbyGames",
+			GameID: websocket.LobbyRoom,
+			Data:   lobbyListing(gameManager, game.ListFilter{}),
+		})
+
+		// Lobby clients only listen; keep reading until they disconnect
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	app.Get("/ws/:gameId", s.authorizeGame, fiberWs.New(func(c *fiberWs.Conn) {
+		gameID := c.Params("gameId")
+		if id, err := gameManager.ResolveGameID(gameID); err == nil {
+			gameID = id
+		}
+		gameLogger := logger.With(logging.KeyGameID, gameID)
+		clientLogger := gameLogger
+
+		// Create new client
+		client := wsManager.NewClient(c, gameID)
+		client.Kind = websocket.ClientPlayer
+		s.limitMessageSize(c)
+
+		// Spectators may only watch public games
+		if c.Query("spectate") == "true" {
+			if g, err := gameManager.GetGame(gameID); err != nil || g.Visibility != game.VisibilityPublic {
+				c.WriteJSON(errorMessage(errPrivateSpectate))
+				c.Close()
+				return
+			}
+			client.Kind = websocket.ClientSpectator
+			client.View = websocket.ViewRedacted
+			if websocket.SpectatorView(c.Query("view")) == websocket.ViewOmniscient {
+				client.View = websocket.ViewOmniscient
+			}
+		}
+
+		// Register client
+		wsManager.Register <- client
+		clientLogger.Info("websocket connected", "spectator", client.IsSpectator())
+
+		// Send initial game state and player count. Spectators always start
+		// from the redacted state; omniscient ones catch up after the delay.
+		// Players see their own secrets only once they have joined.
+		if game, err := gameManager.GetGame(gameID); err == nil {
+			clientLogger.Debug("sending initial game state", "players", len(game.Players))
+			state := game.PlayerView(client.PlayerID)
+			if client.IsSpectator() {
+				state = game.SpectatorView()
+			}
+			client.WriteJSON(websocket.Message{
+				Type: "gameState",
+				Data: state,
+			})
+			client.WriteJSON(websocket.Message{
+				Type: "playerCount",
+				Data: len(game.Players),
+			})
+		}
+
+		defer func() {
+			wsManager.Unregister <- client
+			s.messageLimit.Forget(client.ID)
+			// When a client disconnects, update player count
+			if game, err := gameManager.GetGame(gameID); err == nil {
+				wsManager.SendToGame(gameID, websocket.Message{
+					Type: "playerCount",
+					Data: len(game.Players),
+				})
+			}
+			c.Close()
+		}()
+
+		// throttled is set while messages are being dropped, so the client
+		// is told once rather than for every message
+		throttled := false
+		for {
+			messageType, msg, err := c.ReadMessage()
+			if errors.Is(err, fasthttpWs.ErrReadLimit) {
+				clientLogger.Warn("websocket message too large", "limit", s.config.Limits.MaxMessageSize)
+				return
+			}
+			if err != nil {
+				if fiberWs.IsUnexpectedCloseError(err, fiberWs.CloseGoingAway, fiberWs.CloseAbnormalClosure) {
+					clientLogger.Warn("websocket closed unexpectedly", "error", err)
+				}
+				return
+			}
+
+			if allowed, _ := s.messageLimit.Allow(client.ID); !allowed {
+				s.metrics.RateLimited("message")
+				if !throttled {
+					throttled = true
+					clientLogger.Warn("websocket messages rate limited")
+					client.WriteJSON(errorMessage(errTooManyMessages))
+				}
+				continue
+			}
+			throttled = false
+
+			var message websocket.Message
+			if err := json.Unmarshal(msg, &message); err != nil {
+				clientLogger.Warn("invalid websocket message", "error", err)
+				client.WriteJSON(errorMessage(errInvalidMessage))
+				continue
+			}
+
+			// Some message types give the sender's role away, so the sender
+			// is only named under the redacted actor key
+			gameLogger.Debug("message received", "type", message.Type, logging.KeyActor, client.PlayerID)
+			if messageTypes[message.Type] {
+				s.metrics.MessageReceived(message.Type)
+			} else {
+				s.metrics.MessageReceived("unknown")
+			}
+
+			// Spectators can only talk in the graveyard
+			if client.IsSpectator() && message.Type != "chat" {
+				client.WriteJSON(errorMessage(errSpectatorsChat))
+				continue
+			}
+
+			switch message.Type {
+			case "join":
+				joinData, _ := message.Data.(map[string]interface{})
+				playerName, ok := joinData["playerName"].(string)
+				if !ok {
+					client.WriteJSON(errorMessage(errInvalidJoin))
+					continue
+				}
+				token, _ := joinData["token"].(string)
+				// Match the ID the player was seated under
+				if normalized, err := game.NormalizePlayerName(playerName); err == nil {
+					playerName = normalized
+				}
+				// Only seated players can act, and only with the token they
+				// were given; everyone else keeps watching
+				if err := gameManager.Authenticate(gameID, playerName, token); err != nil {
+					clientLogger.Warn("join rejected", "error", err)
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+				snapshot, err := gameManager.GetGame(gameID)
+				if err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+				// A player who connects again takes the seat over from
+				// their old connection
+				wsManager.SetPlayer(client, playerName)
+				clientLogger = clientLogger.With(logging.KeyPlayerID, playerName)
+				clientLogger.Info("player connected")
+				client.WriteJSON(websocket.Message{
+					Type: "gameState",
+					Data: snapshot.PlayerView(playerName),
+				})
+			case "mafiaAction":
+				target, ok := message.Data.(string)
+				if !ok {
+					client.WriteJSON(errorMessage(game.ErrInvalidTarget))
+					continue
+				}
+				if err := gameManager.HandleMafiaAction(gameID, client.PlayerID, target); err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+
+				// The night only ends on its timer, so the godfather and every
+				// other night role still get to act after this vote
+				game, _ := gameManager.GetGame(gameID)
+				if game != nil {
+					// Notify other mafia members about the vote
+					for _, c := range wsManager.GetGameClients(gameID) {
+						if p, exists := game.Players[c.PlayerID]; exists && p.IsMafia() {
+							c.WriteJSON(websocket.Message{
+								Type: "mafiaVote",
+								Data: map[string]string{
+									"voter":  client.PlayerID,
+									"target": target,
+								},
+							})
+						}
+					}
+				}
+			case "nightAction":
+				target, ok := message.Data.(string)
+				if !ok {
+					client.WriteJSON(errorMessage(game.ErrInvalidTarget))
+					continue
+				}
+				if err := gameManager.HandleNightAction(gameID, client.PlayerID, target); err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+			case "vote":
+				target, ok := message.Data.(string)
+				if !ok {
+					client.WriteJSON(errorMessage(game.ErrInvalidTarget))
+					continue
+				}
+				if err := gameManager.HandleVote(gameID, client.PlayerID, target); err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+			case "reveal":
+				if err := gameManager.RevealMayor(gameID, client.PlayerID); err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+
+				if game, err := gameManager.GetGame(gameID); err == nil {
+					s.broadcastGameState(game)
+				}
+			case "forfeit":
+				if err := gameManager.ForfeitPlayer(gameID, client.PlayerID); err != nil {
+					client.WriteJSON(errorMessage(err))
+					continue
+				}
+
+				if game, err := gameManager.GetGame(gameID); err == nil {
+					s.broadcastGameState(game)
+				}
+			case "chat":
+				// Spectators and dead players share the graveyard channel,
+				// which the living cannot see
+				g, err := gameManager.GetGame(gameID)
+				if err != nil {
+					continue
+				}
+				// Connections that have not taken a seat only watch
+				if _, seated := g.Players[client.PlayerID]; !seated && !client.IsSpectator() {
+					client.WriteJSON(errorMessage(errChatBeforeJoin))
+					continue
+				}
+				// Chat stays in the sender's game and is always signed by
+				// their own seat
+				message.GameID, message.PlayerID = gameID, client.PlayerID
+				if client.IsSpectator() || isDead(g, client.PlayerID) {
+					message.Channel = websocket.ChannelGraveyard
+					wsManager.SendTo(gameID, func(c *websocket.Client) bool {
+						return c.IsSpectator() || isDead(g, c.PlayerID)
+					}, message)
+					continue
+				}
+				message.Channel = ""
+				wsManager.SendToGame(gameID, message)
+			}
+
+			if err := client.WriteMessage(messageType, msg); err != nil {
+				clientLogger.Warn("websocket write failed", "error", err)
+				return
+			}
+		}
+	}))
+}
+
+// limitMessageSize makes the connection refuse messages over the size limit.
+func (s *Server) limitMessageSize(c *fiberWs.Conn) {
+	if s.config.Limits.MaxMessageSize > 0 {
+		c.SetReadLimit(int64(s.config.Limits.MaxMessageSize))
+	}
+}
+
+// errorMessage is the "error" message telling a client why what it sent was
+// refused, with the same code the REST API would use.
+func errorMessage(err error) websocket.Message {
+	code := game.ErrorCode(err)
+	message := err.Error()
+	if code == game.CodeInternal {
+		message = "internal server error"
+	}
+	return websocket.Message{
+		Type: "error",
+		Code: string(code),
+		Data: message,
+	}
+}
+
+// authorizeGame only lets a websocket connect to an existing game, and to a
+// password-protected one only with its password in the "password" query
+// parameter. Guesses are throttled like REST joins.
+func (s *Server) authorizeGame(c *fiber.Ctx) error {
+	gameID, err := s.games.ResolveGameID(c.Params("gameId"))
+	if err != nil {
+		return err
+	}
+	if err := s.games.CheckPassword(gameID, c.IP(), c.Query("password")); err != nil {
+		s.logger.Warn("websocket rejected", logging.KeyGameID, gameID, "ip", c.IP(), "error", err)
+		return err
+	}
+	return c.Next()
+}
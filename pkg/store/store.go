@@ -0,0 +1,101 @@
+// Package store saves the games running on a server so that they survive a
+// restart.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/silent-vendetta/pkg/game"
+)
+
+// Store keeps the records of the games that were running when the server
+// last shut down.
+type Store interface {
+	Save(records []game.Record) error
+	Load() ([]game.Record, error)
+}
+
+// Memory is a Store that lives only as long as the process.
+type Memory struct {
+	records []game.Record
+	mu      sync.RWMutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (s *Memory) Save(records []game.Record) error {
+	s.mu.Lock()
+	s.records = append([]game.Record(nil), records...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Memory) Load() ([]game.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]game.Record(nil), s.records...), nil
+}
+
+// File is a Store that keeps the records as JSON in a single file.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Save replaces the file atomically, so a crash mid-write leaves the
+// previous save intact.
+func (s *File) Save(records []game.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Load reads the saved records. A missing file means nothing was saved.
+func (s *File) Load() ([]game.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []game.Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,224 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/silent-vendetta/pkg/game"
+)
+
+// Message is a websocket message as received by a client. Data is kept raw
+// so it can be decoded into whatever the message type carries.
+type Message struct {
+	Type     string          `json:"type"`
+	GameID   string          `json:"gameId"`
+	PlayerID string          `json:"playerId"`
+	Channel  string          `json:"channel,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Decode unmarshals the message data into v.
+func (m Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+// Client is a scripted player connected to a game's websocket.
+type Client struct {
+	PlayerID string
+	GameID   string
+
+	conn    *fastws.Conn
+	timeout time.Duration
+	// queue holds received messages until they are expected. It is never
+	// bounded, so a slow script cannot stall the server's writes.
+	queue  []Message
+	closed bool
+	ready  chan struct{}
+	// state is the latest game state the client received.
+	state *game.Snapshot
+	mu    sync.Mutex
+}
+
+func newClient(conn *fastws.Conn, gameID string, playerID string, timeout time.Duration) *Client {
+	c := &Client{
+		PlayerID: playerID,
+		GameID:   gameID,
+		conn:     conn,
+		timeout:  timeout,
+		ready:    make(chan struct{}, 1),
+	}
+	go c.read()
+	return c
+}
+
+// read queues incoming messages until the connection closes.
+func (c *Client) read() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		c.signal()
+	}()
+
+	for {
+		var msg Message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		if msg.Type == "gameState" {
+			var state game.Snapshot
+			if err := msg.Decode(&state); err == nil {
+				c.state = &state
+			}
+		}
+		c.queue = append(c.queue, msg)
+		c.mu.Unlock()
+		c.signal()
+	}
+}
+
+// signal wakes up a waiting Expect.
+func (c *Client) signal() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next pops the oldest queued message.
+func (c *Client) next() (msg Message, ok bool, closed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.queue) == 0 {
+		return Message{}, false, c.closed
+	}
+	msg = c.queue[0]
+	c.queue = c.queue[1:]
+	return msg, true, false
+}
+
+// State returns the latest game state the client received.
+func (c *Client) State() *game.Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// Send writes a message without waiting for the server to handle it.
+func (c *Client) Send(msgType string, data interface{}) error {
+	return c.conn.WriteJSON(map[string]interface{}{
+		"type":     msgType,
+		"gameId":   c.GameID,
+		"playerId": c.PlayerID,
+		"data":     data,
+	})
+}
+
+// Expect discards messages until one of the given type arrives.
+func (c *Client) Expect(msgType string) (Message, error) {
+	return c.ExpectFunc(func(msg Message) bool {
+		return msg.Type == msgType
+	})
+}
+
+// ExpectFunc discards messages until match accepts one.
+func (c *Client) ExpectFunc(match func(Message) bool) (Message, error) {
+	timeout := time.After(c.timeout)
+	for {
+		msg, ok, closed := c.next()
+		switch {
+		case ok:
+			if match(msg) {
+				return msg, nil
+			}
+			continue
+		case closed:
+			return Message{}, fmt.Errorf("%s: connection closed", c.PlayerID)
+		}
+
+		select {
+		case <-c.ready:
+		case <-timeout:
+			return Message{}, fmt.Errorf("%s: timed out waiting for message", c.PlayerID)
+		}
+	}
+}
+
+// ExpectState discards messages until a game state accepted by match
+// arrives.
+func (c *Client) ExpectState(match func(*game.Snapshot) bool) (*game.Snapshot, error) {
+	var state game.Snapshot
+	_, err := c.ExpectFunc(func(msg Message) bool {
+		if msg.Type != "gameState" || msg.Decode(&state) != nil {
+			return false
+		}
+		return match(&state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ExpectPhase waits for the game to reach the given phase.
+func (c *Client) ExpectPhase(phase game.Phase) (*game.Snapshot, error) {
+	return c.ExpectState(func(s *game.Snapshot) bool {
+		return s.Phase == phase
+	})
+}
+
+// call sends a message and waits until the server has handled it. The
+// server echoes handled messages back to the sender, and answers failed
+// ones with an error.
+func (c *Client) call(msgType string, data interface{}) error {
+	if err := c.Send(msgType, data); err != nil {
+		return err
+	}
+	msg, err := c.ExpectFunc(func(msg Message) bool {
+		return msg.Type == msgType || msg.Type == "error"
+	})
+	if err != nil {
+		return err
+	}
+	if msg.Type == "error" {
+		var reason string
+		msg.Decode(&reason)
+		return fmt.Errorf("%s: %s rejected: %s", c.PlayerID, msgType, reason)
+	}
+	return nil
+}
+
+// Vote votes to eliminate the target during the day.
+func (c *Client) Vote(targetID string) error {
+	return c.call("vote", targetID)
+}
+
+// Act uses the player's night ability on the target: mafia vote on their
+// kill, every other role performs its own action.
+func (c *Client) Act(targetID string) error {
+	state := c.State()
+	if state == nil {
+		return fmt.Errorf("%s: no game state received", c.PlayerID)
+	}
+	if p, exists := state.Players[c.PlayerID]; exists && p.IsMafia() {
+		return c.call("mafiaAction", targetID)
+	}
+	return c.call("nightAction", targetID)
+}
+
+// Chat sends a chat message.
+func (c *Client) Chat(text string) error {
+	return c.Send("chat", text)
+}
+
+// Close disconnects the client.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,236 @@
+// Package testkit runs the server in memory and drives it with scripted
+// websocket clients and a fake clock, so whole games can be played from
+// tests without a browser or a network.
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/silent-vendetta/pkg/clock"
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/server"
+	"github.com/silent-vendetta/pkg/websocket"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// Epoch is the time the fake clock of every harness starts at.
+var Epoch = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// DefaultTimeout is how long clients wait for an expected message.
+const DefaultTimeout = 2 * time.Second
+
+// Harness is a server running on an in-memory listener. Phase timers run on
+// Clock and only expire when it is moved.
+type Harness struct {
+	Clock  *clock.Fake
+	Games  *game.GameManager
+	Server *server.Server
+	// Timeout bounds how long clients wait for messages.
+	Timeout time.Duration
+
+	listener *fasthttputil.InmemoryListener
+	// tokens holds the token each player was seated with, by game ID and
+	// player ID.
+	tokens map[string]map[string]string
+	mu     sync.Mutex
+}
+
+// New starts a server whose games deal roles from the given seed.
+func New(seed int64) *Harness {
+	fake := clock.NewFake(Epoch)
+	games := game.NewGameManager(
+		game.WithClock(fake),
+		game.WithSeeder(func() int64 { return seed }),
+	)
+	wsManager := websocket.NewManager()
+	go wsManager.Start()
+
+	srv := server.New(server.Config{DisableStartupMessage: true}, server.Dependencies{
+		Games:     games,
+		WebSocket: wsManager,
+	})
+
+	h := &Harness{
+		Clock:    fake,
+		Games:    games,
+		Server:   srv,
+		Timeout:  DefaultTimeout,
+		listener: fasthttputil.NewInmemoryListener(),
+		tokens:   make(map[string]map[string]string),
+	}
+	go h.Server.Routes().Listener(h.listener)
+	return h
+}
+
+// Close shuts the server down, disconnecting every client.
+func (h *Harness) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	return h.Server.Shutdown(ctx)
+}
+
+// request sends a REST request and decodes the JSON response into out.
+// Responses with an error status are returned as errors.
+func (h *Harness) request(method string, path string, body interface{}, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.Server.Routes().Test(req, int(h.Timeout/time.Millisecond))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// seated is the part of the create and join responses that identifies the
+// player.
+type seated struct {
+	GameID   string `json:"gameId"`
+	PlayerID string `json:"playerId"`
+	Token    string `json:"token"`
+}
+
+// remember keeps the token a player was seated with.
+func (h *Harness) remember(resp seated) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tokens[resp.GameID] == nil {
+		h.tokens[resp.GameID] = make(map[string]string)
+	}
+	h.tokens[resp.GameID][resp.PlayerID] = resp.Token
+}
+
+// Token returns the token a player was seated with, or "" if they were not
+// seated through the harness.
+func (h *Harness) Token(gameID string, playerID string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.tokens[gameID][playerID]
+}
+
+// CreateGame opens a lobby hosted by the named player and returns its ID.
+// Nil settings keep the defaults.
+func (h *Harness) CreateGame(host string, settings *game.GameSettings) (string, error) {
+	var resp seated
+	err := h.request(http.MethodPost, "/api/games", server.CreateGameRequest{
+		PlayerName: host,
+		Settings:   settings,
+	}, &resp)
+	if err == nil {
+		h.remember(resp)
+	}
+	return resp.GameID, err
+}
+
+// Join seats the named player in a lobby.
+func (h *Harness) Join(gameID string, name string) error {
+	var resp seated
+	err := h.request(http.MethodPost, "/api/games/"+gameID+"/join", server.JoinGameRequest{
+		PlayerName: name,
+	}, &resp)
+	if err == nil {
+		h.remember(resp)
+	}
+	return err
+}
+
+// Start deals the roles and begins the first night on behalf of the host.
+func (h *Harness) Start(gameID string, host string) error {
+	return h.request(http.MethodPost, "/api/games/"+gameID+"/start", server.StartGameRequest{
+		HostRequest: server.HostRequest{PlayerID: host, Token: h.Token(gameID, host)},
+	}, nil)
+}
+
+// Connect opens a websocket for the player and announces who they are with
+// the token they were seated with. Player IDs are the names players joined
+// with.
+func (h *Harness) Connect(gameID string, playerID string) (*Client, error) {
+	return h.ConnectWithToken(gameID, playerID, h.Token(gameID, playerID))
+}
+
+// ConnectWithToken opens a websocket for the player and announces who they
+// are with the given token.
+func (h *Harness) ConnectWithToken(gameID string, playerID string, token string) (*Client, error) {
+	c, err := h.dial(gameID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.call("join", map[string]string{"playerName": playerID, "token": token}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Watch opens a websocket without joining, which sees what spectators see.
+func (h *Harness) Watch(gameID string) (*Client, error) {
+	return h.dial(gameID, "")
+}
+
+// dial opens a websocket to a game and waits for the initial game state.
+func (h *Harness) dial(gameID string, playerID string) (*Client, error) {
+	dialer := fastws.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			return h.listener.Dial()
+		},
+		HandshakeTimeout: h.Timeout,
+	}
+	conn, _, err := dialer.Dial("ws://testkit/ws/"+gameID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(conn, gameID, playerID, h.Timeout)
+	if _, err := c.Expect("gameState"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ExpirePhase moves the clock to the end of the game's current phase, so
+// its timer fires.
+func (h *Harness) ExpirePhase(gameID string) error {
+	snapshot, err := h.Games.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+	h.Clock.Set(snapshot.PhaseEndTime)
+	return nil
+}
@@ -0,0 +1,270 @@
+package testkit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silent-vendetta/pkg/game"
+	"github.com/silent-vendetta/pkg/server"
+)
+
+// TestJoinRequiresToken checks that a websocket can only take a seat with
+// the token the player was given, and that it takes the seat over from the
+// player's previous connection.
+func TestJoinRequiresToken(t *testing.T) {
+	h := New(1)
+	defer h.Close()
+
+	gameID, err := h.CreateGame("alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Join(gameID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := h.ConnectWithToken(gameID, "bob", ""); err == nil {
+		c.Close()
+		t.Fatal("joined without a token")
+	}
+	if c, err := h.ConnectWithToken(gameID, "bob", h.Token(gameID, "alice")); err == nil {
+		c.Close()
+		t.Fatal("joined with another player's token")
+	}
+
+	first, err := h.Connect(gameID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := h.Connect(gameID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if _, err := first.Expect("never sent"); err == nil || err.Error() != "bob: connection closed" {
+		t.Fatalf("first connection still open after the player reconnected: %v", err)
+	}
+}
+
+// TestHostRequestsRequireToken checks that naming the host is not enough to
+// act as them over REST.
+func TestHostRequestsRequireToken(t *testing.T) {
+	h := New(1)
+	defer h.Close()
+
+	gameID, err := h.CreateGame("alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Join(gameID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := game.DefaultSettings()
+	settings.MinPlayers = 3
+	tests := []struct {
+		name      string
+		path      string
+		player    string
+		withToken bool
+		status    int
+	}{
+		{"settings without a token", "/settings", "alice", false, http.StatusUnauthorized},
+		{"settings by a guest", "/settings", "bob", true, http.StatusForbidden},
+		{"bots without a token", "/bots", "alice", false, http.StatusUnauthorized},
+		{"rematch without a token", "/rematch", "alice", false, http.StatusUnauthorized},
+		{"start without a token", "/start", "alice", false, http.StatusUnauthorized},
+		{"start by a guest", "/start", "bob", true, http.StatusForbidden},
+		{"settings by the host", "/settings", "alice", true, http.StatusOK},
+		{"bots by the host", "/bots", "alice", true, http.StatusOK},
+		{"start by the host", "/start", "alice", true, http.StatusOK},
+		{"next phase", "/next-phase", "alice", true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := server.HostRequest{PlayerID: tt.player}
+			if tt.withToken {
+				host.Token = h.Token(gameID, tt.player)
+			}
+			body := server.UpdateSettingsRequest{HostRequest: host, GameSettings: settings}
+			err := h.request(http.MethodPost, "/api/games/"+gameID+tt.path, body, nil)
+			if tt.status == http.StatusOK {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if want := fmt.Sprintf(": %d ", tt.status); err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("got %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+// TestInvestigationResultsStayInTheirGame checks that a detective's result
+// only goes to them, even when a player of another game has the same name.
+func TestInvestigationResultsStayInTheirGame(t *testing.T) {
+	h := New(1)
+	defer h.Close()
+
+	players := []string{"alice", "bob", "carol", "dave", "erin"}
+	clients := make(map[string]map[string]*Client)
+	var gameIDs []string
+	for i := 0; i < 2; i++ {
+		gameID, err := h.CreateGame(players[0], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range players[1:] {
+			if err := h.Join(gameID, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		clients[gameID] = make(map[string]*Client)
+		for _, name := range players {
+			c, err := h.Connect(gameID, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			clients[gameID][name] = c
+		}
+		gameIDs = append(gameIDs, gameID)
+	}
+
+	played, other := gameIDs[0], gameIDs[1]
+	if err := h.Start(played, players[0]); err != nil {
+		t.Fatal(err)
+	}
+	state, err := h.Games.GetGame(played)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var detective, target string
+	for _, name := range players {
+		switch {
+		case state.Players[name].Role == game.RoleDetective:
+			detective = name
+		case target == "":
+			target = name
+		}
+	}
+	if detective == "" {
+		t.Fatal("no detective was dealt")
+	}
+	if _, err := clients[played][detective].ExpectPhase(game.PhaseNight); err != nil {
+		t.Fatal(err)
+	}
+	if err := clients[played][detective].Act(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ExpirePhase(played); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := clients[played][detective].Expect("investigationResult")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.GameID != played {
+		t.Fatalf("result sent for game %s, want %s", msg.GameID, played)
+	}
+	namesake := clients[other][detective]
+	namesake.timeout = 100 * time.Millisecond
+	if _, err := namesake.Expect("investigationResult"); err == nil {
+		t.Fatal("result also sent to a player of another game")
+	}
+}
+
+// TestChatStaysInItsGame checks that a chat message goes to the sender's
+// game under the sender's name, whatever game and player it claims.
+func TestChatStaysInItsGame(t *testing.T) {
+	h := New(1)
+	defer h.Close()
+
+	clients := make(map[string]*Client)
+	var gameIDs []string
+	for _, host := range []string{"alice", "bob"} {
+		gameID, err := h.CreateGame(host, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := h.Connect(gameID, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		clients[host] = c
+		gameIDs = append(gameIDs, gameID)
+	}
+
+	// Player counts reach the game they are about
+	if err := h.Join(gameIDs[0], "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients["alice"].Expect("playerCount"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := clients["alice"].conn.WriteJSON(map[string]interface{}{
+		"type":     "chat",
+		"gameId":   gameIDs[1],
+		"playerId": "bob",
+		"data":     "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := clients["alice"].ExpectFunc(func(msg Message) bool {
+		return msg.Type == "chat" && msg.GameID == gameIDs[0]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.PlayerID != "alice" {
+		t.Fatalf("chat signed by %q, want alice", msg.PlayerID)
+	}
+
+	clients["bob"].timeout = 100 * time.Millisecond
+	if _, err := clients["bob"].Expect("chat"); err == nil {
+		t.Fatal("chat delivered to the game it claimed")
+	}
+}
+
+// TestChatNeedsASeat checks that a connection that never joined cannot
+// talk to the players.
+func TestChatNeedsASeat(t *testing.T) {
+	h := New(1)
+	defer h.Close()
+
+	gameID, err := h.CreateGame("alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := h.Connect(gameID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	watcher, err := h.Watch(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Chat("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.Expect("error"); err != nil {
+		t.Fatal(err)
+	}
+	alice.timeout = 100 * time.Millisecond
+	if _, err := alice.Expect("chat"); err == nil {
+		t.Fatal("chat from an unseated connection reached the players")
+	}
+}
@@ -0,0 +1,256 @@
+package testkit
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/silent-vendetta/pkg/game"
+)
+
+// Kinds of scripted moves.
+const (
+	// ActionAct uses a night ability; mafia vote on their kill.
+	ActionAct = "act"
+	// ActionVote votes to eliminate the target during the day.
+	ActionVote = "vote"
+)
+
+// Action is a scripted move. Players are chosen by role rather than by name,
+// so a scenario holds whatever order the roles are dealt in.
+type Action struct {
+	// By is the role of the player making the move: the first living
+	// player with it, by name. An empty role makes every living player move.
+	By   game.Role
+	Kind string
+	// Target is the role of the target: the first living player with it,
+	// by name, other than the actor. Actors without a target skip the move.
+	Target game.Role
+}
+
+// Step plays the actions in order, then lets the phase timer expire if the
+// moves did not already end the phase.
+type Step struct {
+	Actions []Action
+	// Phase is the phase the game must be in after the step.
+	Phase game.Phase
+	// Alive, if set, is the number of players that must be alive after the
+	// step.
+	Alive int
+}
+
+// Scenario is a complete game played from the lobby.
+type Scenario struct {
+	Name string
+	Seed int64
+	// Players are the names of the players; the first one hosts.
+	Players []string
+	// Settings replace the defaults if set.
+	Settings *game.GameSettings
+	Steps    []Step
+	// Winners are the factions that must have won once the steps are done.
+	Winners []game.Faction
+}
+
+var fourPlayers = []string{"alice", "bob", "carol", "dave"}
+
+// Scenarios are complete games covering the main ways a game can end.
+var Scenarios = []Scenario{
+	{
+		Name:    "town lynches the mafia",
+		Seed:    1,
+		Players: fourPlayers,
+		Steps: []Step{
+			{Actions: []Action{{By: game.RoleMafia, Kind: ActionAct, Target: game.RoleVillager}}, Phase: game.PhaseDiscuss, Alive: 3},
+			{Phase: game.PhaseVote},
+			{Actions: []Action{{Kind: ActionVote, Target: game.RoleMafia}}, Phase: game.PhaseGameOver, Alive: 2},
+		},
+		Winners: []game.Faction{game.FactionTown},
+	},
+	{
+		Name:    "mafia reaches parity",
+		Seed:    2,
+		Players: fourPlayers,
+		Steps: []Step{
+			{Actions: []Action{{By: game.RoleMafia, Kind: ActionAct, Target: game.RoleVillager}}, Phase: game.PhaseDiscuss, Alive: 3},
+			{Phase: game.PhaseVote},
+			{Actions: []Action{{Kind: ActionVote, Target: game.RoleVillager}}, Phase: game.PhaseGameOver, Alive: 2},
+		},
+		Winners: []game.Faction{game.FactionMafia},
+	},
+	{
+		Name:    "nobody dies when nobody acts",
+		Seed:    3,
+		Players: fourPlayers,
+		Steps: []Step{
+			{Phase: game.PhaseDiscuss, Alive: 4},
+			{Phase: game.PhaseVote},
+			{Phase: game.PhaseNight, Alive: 4},
+			{Actions: []Action{{By: game.RoleMafia, Kind: ActionAct, Target: game.RoleVillager}}, Phase: game.PhaseDiscuss, Alive: 3},
+			{Phase: game.PhaseVote},
+			{Actions: []Action{{Kind: ActionVote, Target: game.RoleMafia}}, Phase: game.PhaseGameOver, Alive: 2},
+		},
+		Winners: []game.Faction{game.FactionTown},
+	},
+	{
+		Name:    "medic saves the mafia's target",
+		Seed:    4,
+		Players: []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace"},
+		Steps: []Step{
+			{
+				Actions: []Action{
+					{By: game.RoleMedic, Kind: ActionAct, Target: game.RoleVillager},
+					{By: game.RoleMafia, Kind: ActionAct, Target: game.RoleVillager},
+				},
+				Phase: game.PhaseDiscuss,
+				Alive: 7,
+			},
+			{Phase: game.PhaseVote},
+			{Actions: []Action{{Kind: ActionVote, Target: game.RoleMafia}}, Phase: game.PhaseNight, Alive: 6},
+			{Actions: []Action{{By: game.RoleMafia, Kind: ActionAct, Target: game.RoleVillager}}, Phase: game.PhaseDiscuss, Alive: 5},
+			{Phase: game.PhaseVote},
+			{Actions: []Action{{Kind: ActionVote, Target: game.RoleMafia}}, Phase: game.PhaseGameOver, Alive: 4},
+		},
+		Winners: []game.Faction{game.FactionTown},
+	},
+}
+
+// Run plays a scenario on a fresh harness and reports the first way the game
+// strayed from the script.
+func Run(s Scenario) error {
+	h := New(s.Seed)
+	defer h.Close()
+
+	if len(s.Players) == 0 {
+		return fmt.Errorf("%s: no players", s.Name)
+	}
+	gameID, err := h.CreateGame(s.Players[0], s.Settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+	for _, name := range s.Players[1:] {
+		if err := h.Join(gameID, name); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+
+	clients := make(map[string]*Client, len(s.Players))
+	for _, name := range s.Players {
+		c, err := h.Connect(gameID, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+		defer c.Close()
+		clients[name] = c
+	}
+	host := clients[s.Players[0]]
+
+	if err := h.Start(gameID, s.Players[0]); err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+	for _, c := range clients {
+		if _, err := c.ExpectPhase(game.PhaseNight); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+
+	for i, step := range s.Steps {
+		before, err := h.Games.GetGame(gameID)
+		if err != nil {
+			return fmt.Errorf("%s: step %d: %w", s.Name, i+1, err)
+		}
+
+		for _, action := range step.Actions {
+			if err := perform(h, gameID, clients, action); err != nil {
+				return fmt.Errorf("%s: step %d: %w", s.Name, i+1, err)
+			}
+		}
+
+		current, err := h.Games.GetGame(gameID)
+		if err != nil {
+			return fmt.Errorf("%s: step %d: %w", s.Name, i+1, err)
+		}
+		if current.Phase == before.Phase && current.Round == before.Round {
+			h.Clock.Set(current.PhaseEndTime)
+		}
+
+		state, err := host.ExpectState(func(state *game.Snapshot) bool {
+			return state.Phase == step.Phase && (state.Phase != before.Phase || state.Round != before.Round)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: step %d: expected phase %s: %w", s.Name, i+1, step.Phase, err)
+		}
+		if step.Alive > 0 && len(state.AlivePlayers()) != step.Alive {
+			return fmt.Errorf("%s: step %d: %d players alive, expected %d", s.Name, i+1, len(state.AlivePlayers()), step.Alive)
+		}
+	}
+
+	final, err := h.Games.GetGame(gameID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+	if len(s.Winners) > 0 {
+		if final.Result == nil {
+			return fmt.Errorf("%s: game is not over", s.Name)
+		}
+		if fmt.Sprint(final.Result.Factions) != fmt.Sprint(s.Winners) {
+			return fmt.Errorf("%s: %v won, expected %v", s.Name, final.Result.Factions, s.Winners)
+		}
+	}
+	return nil
+}
+
+// perform plays one scripted action through the actors' websocket clients.
+func perform(h *Harness, gameID string, clients map[string]*Client, action Action) error {
+	state, err := h.Games.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	alive := state.AlivePlayers()
+	sort.Slice(alive, func(i, j int) bool {
+		return alive[i].Name < alive[j].Name
+	})
+
+	var actors []*game.Player
+	for _, p := range alive {
+		if action.By == "" || p.Role == action.By {
+			actors = append(actors, p)
+			if action.By != "" {
+				break
+			}
+		}
+	}
+	if len(actors) == 0 {
+		return fmt.Errorf("no living %s to %s", action.By, action.Kind)
+	}
+
+	for _, actor := range actors {
+		var target string
+		for _, p := range alive {
+			if p.Role == action.Target && p.ID != actor.ID {
+				target = p.ID
+				break
+			}
+		}
+		if target == "" {
+			continue
+		}
+
+		c, exists := clients[actor.ID]
+		if !exists {
+			return fmt.Errorf("no client for %s", actor.ID)
+		}
+		switch action.Kind {
+		case ActionAct:
+			err = c.Act(target)
+		case ActionVote:
+			err = c.Vote(target)
+		default:
+			err = fmt.Errorf("unknown action %q", action.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
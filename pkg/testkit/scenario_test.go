@@ -0,0 +1,15 @@
+package testkit
+
+import "testing"
+
+// TestScenarios plays every scripted game through the in-memory server.
+func TestScenarios(t *testing.T) {
+	for _, s := range Scenarios {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			if err := Run(s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
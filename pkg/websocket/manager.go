@@ -1,23 +1,125 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"github.com/silent-vendetta/pkg/logging"
 )
 
+// LobbyRoom is the room of clients following the public game list rather
+// than a single game.
+const LobbyRoom = "lobby"
+
+// ClientKind distinguishes seated players from spectators.
+type ClientKind string
+
+const (
+	ClientPlayer    ClientKind = "player"
+	ClientSpectator ClientKind = "spectator"
+)
+
+// SpectatorView is the kind of game state a spectator receives.
+type SpectatorView string
+
+const (
+	// ViewRedacted is a live view with every role hidden until game over.
+	ViewRedacted SpectatorView = "redacted"
+	// ViewOmniscient is a full-information view sent after a delay, for
+	// streaming a game without leaking it to the players.
+	ViewOmniscient SpectatorView = "omniscient"
+)
+
+// ChannelGraveyard is the chat channel shared by spectators and dead players.
+const ChannelGraveyard = "graveyard"
+
+// Observer is told about every message written to a client created by
+// Manager.NewClient.
+type Observer interface {
+	MessageSent(msgType string)
+	WriteFailed(msgType string)
+}
+
 type Client struct {
-	Conn     *websocket.Conn
-	GameID   string
-	PlayerID string
+	// ID identifies clients created by Manager.NewClient.
+	ID          string
+	ConnectedAt time.Time
+	Conn        *websocket.Conn
+	GameID      string
+	PlayerID    string
+	Kind        ClientKind
+	View        SpectatorView
+	observer    Observer
+	writeMu     sync.Mutex
+}
+
+// IsSpectator reports whether the client is watching without a seat.
+func (c *Client) IsSpectator() bool {
+	return c.Kind == ClientSpectator
+}
+
+// WriteJSON sends a message to the client. Writes are serialized so that
+// several goroutines can safely send to the same connection.
+func (c *Client) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	msgType := "unknown"
+	if msg, ok := v.(Message); ok {
+		msgType = msg.Type
+	}
+	return c.observe(msgType, c.Conn.WriteJSON(v))
+}
+
+// WriteMessage sends a raw frame to the client, serialized with WriteJSON.
+// Raw frames are observed with the type "raw".
+func (c *Client) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.observe("raw", c.Conn.WriteMessage(messageType, data))
+}
+
+// observe reports the outcome of a write to the client's observer.
+func (c *Client) observe(msgType string, err error) error {
+	if c.observer == nil {
+		return err
+	}
+	if err != nil {
+		c.observer.WriteFailed(msgType)
+	} else {
+		c.observer.MessageSent(msgType)
+	}
+	return err
+}
+
+// closeTimeout bounds how long sending a close frame may take.
+const closeTimeout = time.Second
+
+// Close sends a close frame with the given code and reason and closes the
+// connection without waiting for the client to answer.
+func (c *Client) Close(code int, text string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	err := c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(closeTimeout))
+	c.Conn.Close()
+	return err
 }
 
 type Message struct {
-	Type     string      `json:"type"`
-	GameID   string      `json:"gameId"`
-	PlayerID string      `json:"playerId"`
-	Data     interface{} `json:"data"`
+	Type     string `json:"type"`
+	GameID   string `json:"gameId"`
+	PlayerID string `json:"playerId"`
+	Channel  string `json:"channel,omitempty"`
+	// Code identifies the error in "error" messages, using the same codes
+	// as the REST API.
+	Code string      `json:"code,omitempty"`
+	Data interface{} `json:"data"`
 }
 
 type Manager struct {
@@ -25,18 +127,64 @@ type Manager struct {
 	Broadcast  chan Message
 	Register   chan *Client
 	Unregister chan *Client
+	logger     *slog.Logger
+	observer   Observer
 	mu         sync.RWMutex
 }
 
-func NewManager() *Manager {
-	return &Manager{
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithLogger sets the logger failed writes are reported to.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithObserver reports every message written to the manager's clients.
+func WithObserver(observer Observer) Option {
+	return func(m *Manager) {
+		m.observer = observer
+	}
+}
+
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
 		clients:    make(map[*Client]bool),
 		Broadcast:  make(chan Message),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
+		logger:     logging.Default(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// NewClient returns a client for conn in the given room, reporting to the
+// manager's observer. It still has to be registered.
+func (m *Manager) NewClient(conn *websocket.Conn, gameID string) *Client {
+	return &Client{
+		ID:          uuid.New().String(),
+		ConnectedAt: time.Now(),
+		Conn:        conn,
+		GameID:      gameID,
+		observer:    m.observer,
 	}
 }
 
+// writeFailed logs a message that could not be sent to a client.
+func (m *Manager) writeFailed(client *Client, message Message, err error) {
+	m.logger.Warn("websocket write failed",
+		logging.KeyGameID, client.GameID,
+		logging.KeyPlayerID, client.PlayerID,
+		"type", message.Type,
+		"error", err,
+	)
+}
+
 func (m *Manager) Start() {
 	for {
 		select {
@@ -54,41 +202,218 @@ func (m *Manager) Start() {
 			m.mu.Unlock()
 
 		case message := <-m.Broadcast:
-			m.mu.RLock()
+			m.mu.Lock()
 			for client := range m.clients {
 				if client.GameID == message.GameID {
-					err := client.Conn.WriteJSON(message)
+					err := client.WriteJSON(message)
 					if err != nil {
-						log.Printf("error: %v", err)
+						m.writeFailed(client, message, err)
 						client.Conn.Close()
 						delete(m.clients, client)
 					}
 				}
 			}
-			m.mu.RUnlock()
+			m.mu.Unlock()
 		}
 	}
 }
 
+// SendToGame sends a message to every client of a game, or of LobbyRoom.
+// The message is addressed to gameID whatever its GameID says.
 func (m *Manager) SendToGame(gameID string, message Message) {
+	message.GameID = gameID
 	m.Broadcast <- message
 }
 
-func (m *Manager) SendToPlayer(playerID string, message Message) {
+// SendToPlayer sends a message to the connections of one player of a game.
+// Player IDs are only unique within a game.
+func (m *Manager) SendToPlayer(gameID string, playerID string, message Message) {
+	m.SendTo(gameID, func(c *Client) bool {
+		return c.PlayerID == playerID && !c.IsSpectator()
+	}, message)
+}
+
+// SetPlayer makes client the connection of a player in its game. Any other
+// connection of the same player is closed, so a seat never has more than
+// one live connection.
+func (m *Manager) SetPlayer(client *Client, playerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for other := range m.clients {
+		if other != client && other.GameID == client.GameID && other.PlayerID == playerID && !other.IsSpectator() {
+			other.Close(websocket.ClosePolicyViolation, "connected from another client")
+			delete(m.clients, other)
+		}
+	}
+	client.PlayerID = playerID
+}
+
+// SendTo sends a message to the clients of a game that match the filter.
+func (m *Manager) SendTo(gameID string, match func(*Client) bool, message Message) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	for client := range m.clients {
-		if client.PlayerID == playerID {
-			err := client.Conn.WriteJSON(message)
-			if err != nil {
-				log.Printf("error sending to player: %v", err)
+		if client.GameID == gameID && match(client) {
+			if err := client.WriteJSON(message); err != nil {
+				m.writeFailed(client, message, err)
 			}
-			return
 		}
 	}
 }
 
+// SendToPlayers sends every player connection of a game the message built
+// for it, so each player can be sent only what they may see.
+func (m *Manager) SendToPlayers(gameID string, build func(*Client) Message) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for client := range m.clients {
+		if client.GameID == gameID && !client.IsSpectator() {
+			message := build(client)
+			if err := client.WriteJSON(message); err != nil {
+				m.writeFailed(client, message, err)
+			}
+		}
+	}
+}
+
+// SendToSpectators sends a message to the spectators of a game that chose
+// the given view.
+func (m *Manager) SendToSpectators(gameID string, view SpectatorView, message Message) {
+	m.SendTo(gameID, func(c *Client) bool {
+		return c.IsSpectator() && c.View == view
+	}, message)
+}
+
+// CloseRoom sends a final message to every client in a game and closes
+// their connections.
+func (m *Manager) CloseRoom(gameID string, message Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for client := range m.clients {
+		if client.GameID == gameID {
+			if err := client.WriteJSON(message); err != nil {
+				m.writeFailed(client, message, err)
+			}
+			client.Conn.Close()
+			delete(m.clients, client)
+		}
+	}
+}
+
+// CloseAll sends a final message to every client of every room, followed by
+// a going-away close frame, and disconnects them.
+func (m *Manager) CloseAll(message Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for client := range m.clients {
+		message.GameID = client.GameID
+		if err := client.WriteJSON(message); err != nil {
+			m.writeFailed(client, message, err)
+		}
+		client.Close(websocket.CloseGoingAway, "server shutting down")
+		delete(m.clients, client)
+	}
+}
+
+// CountClients returns the number of connected clients of each kind.
+// Clients following the lobby are counted under LobbyRoom.
+func (m *Manager) CountClients() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[string]int{
+		string(ClientPlayer):    0,
+		string(ClientSpectator): 0,
+		LobbyRoom:               0,
+	}
+	for client := range m.clients {
+		switch {
+		case client.GameID == LobbyRoom:
+			counts[LobbyRoom]++
+		case client.IsSpectator():
+			counts[string(ClientSpectator)]++
+		default:
+			counts[string(ClientPlayer)]++
+		}
+	}
+	return counts
+}
+
+// CountPlayers returns the number of connections that have joined a game as
+// one of its players.
+func (m *Manager) CountPlayers(gameID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for client := range m.clients {
+		if client.GameID == gameID && client.PlayerID != "" && !client.IsSpectator() {
+			n++
+		}
+	}
+	return n
+}
+
+// ClientInfo describes a connected client.
+type ClientInfo struct {
+	ID          string        `json:"id"`
+	GameID      string        `json:"gameId"`
+	PlayerID    string        `json:"playerId,omitempty"`
+	Kind        ClientKind    `json:"kind,omitempty"`
+	View        SpectatorView `json:"view,omitempty"`
+	RemoteAddr  string        `json:"remoteAddr"`
+	ConnectedAt time.Time     `json:"connectedAt"`
+}
+
+// ListClients describes the connected clients of a room, or of every room
+// if gameID is empty, oldest first.
+func (m *Manager) ListClients(gameID string) []ClientInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]ClientInfo, 0)
+	for client := range m.clients {
+		if gameID != "" && client.GameID != gameID {
+			continue
+		}
+		clients = append(clients, ClientInfo{
+			ID:          client.ID,
+			GameID:      client.GameID,
+			PlayerID:    client.PlayerID,
+			Kind:        client.Kind,
+			View:        client.View,
+			RemoteAddr:  client.Conn.RemoteAddr().String(),
+			ConnectedAt: client.ConnectedAt,
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+	})
+	return clients
+}
+
+// Disconnect sends a policy violation close frame with the given reason to
+// the client with the given ID and drops it. It reports whether the client
+// was found.
+func (m *Manager) Disconnect(clientID string, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for client := range m.clients {
+		if client.ID == clientID {
+			client.Close(websocket.ClosePolicyViolation, reason)
+			delete(m.clients, client)
+			return true
+		}
+	}
+	return false
+}
+
 // GetGameClients returns all clients in a specific game
 func (m *Manager) GetGameClients(gameID string) []*Client {
 	m.mu.RLock()